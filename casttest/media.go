@@ -0,0 +1,302 @@
+package casttest
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/barnybug/go-cast/internal/device"
+	castnet "github.com/barnybug/go-cast/net"
+)
+
+const supportedMediaCommands = controllers.CommandPause | controllers.CommandSeek |
+	controllers.CommandStreamVolume | controllers.CommandStreamMute |
+	controllers.CommandQueueNext | controllers.CommandQueuePrev |
+	controllers.CommandQueueShuffle | controllers.CommandQueueRepeat
+
+// mediaSession is the media loaded in the default media receiver. The
+// position is tracked against the server's simulated clock.
+type mediaSession struct {
+	id           int
+	media        controllers.MediaStatusMedia
+	playerState  string
+	idleReason   string
+	rate         float64
+	volume       controllers.Volume
+	queue        []controllers.QueueItem
+	currentId    int
+	lastId       int
+	repeatMode   string
+	activeTracks []int
+	// position is the playback position at since on the simulated clock
+	position float64
+	since    time.Duration
+	// liveSince is when a live stream started, its position 0
+	liveSince time.Duration
+}
+
+// liveEdgeTolerance is how close to the live edge playback must be to be
+// playing at it.
+const liveEdgeTolerance = 1.0
+
+// SetDuration sets the duration reported for media loaded with contentId.
+// Playback of media with a known duration finishes once the clock passes
+// its end.
+func (s *Server) SetDuration(contentId string, duration time.Duration) {
+	s.device.Lock()
+	defer s.device.Unlock()
+	s.duration[contentId] = duration.Seconds()
+}
+
+// Advance moves the simulated playback clock forward by d. Media that
+// reaches its end moves on to the next item in the queue, and every sender
+// is sent the new MEDIA_STATUS.
+func (s *Server) Advance(d time.Duration) {
+	s.device.Lock()
+	defer s.device.Unlock()
+	s.clock += d
+
+	m := s.media
+	changed := false
+	for m != nil && m.playerState == "PLAYING" && m.media.Duration > 0 && m.rate > 0 {
+		position := m.position + (s.clock-m.since).Seconds()*m.rate
+		if position < m.media.Duration {
+			break
+		}
+		changed = true
+		// the clock time at which the item ended
+		ended := s.clock - time.Duration((position-m.media.Duration)/m.rate*float64(time.Second))
+		next, ok := s.nextItem(m, 1, true)
+		if !ok {
+			s.seek(m, m.media.Duration)
+			m.playerState = "IDLE"
+			m.idleReason = "FINISHED"
+			break
+		}
+		s.playItem(m, next)
+		m.since = ended
+	}
+	if !changed {
+		return
+	}
+
+	s.device.BroadcastMedia(s.mediaStatus(nil))
+}
+
+// Cast launches appId with the media namespace and starts playing item in
+// it, as a phone casting from another app would, telling every sender.
+func (s *Server) Cast(appId string, item controllers.MediaItem) {
+	s.device.Lock()
+	defer s.device.Unlock()
+	s.device.Launch(appId, controllers.NamespaceMedia)
+	s.load(item, 0, true, nil)
+	s.device.BroadcastMedia(s.mediaStatus(nil))
+}
+
+// MediaStatus returns the status of the loaded media, or nil if there is
+// none.
+func (s *Server) MediaStatus() *controllers.MediaStatus {
+	s.device.Lock()
+	defer s.device.Unlock()
+	if s.media == nil {
+		return nil
+	}
+	return s.statusOf(s.media)
+}
+
+// positionOf returns the playback position of m at the current simulated
+// time. Must be called with the lock held.
+func (s *Server) positionOf(m *mediaSession) float64 {
+	position := m.position
+	if m.playerState == "PLAYING" {
+		position += (s.clock - m.since).Seconds() * m.rate
+	}
+	if m.media.Duration > 0 && position > m.media.Duration {
+		position = m.media.Duration
+	}
+	if m.media.StreamType == controllers.StreamTypeLive {
+		position = math.Min(position, s.liveEdge(m))
+	}
+	return position
+}
+
+// liveEdge returns how far a live stream has got. Must be called with the
+// lock held.
+func (s *Server) liveEdge(m *mediaSession) float64 {
+	return (s.clock - m.liveSince).Seconds()
+}
+
+// seek records the current position before the player state changes. Must
+// be called with the lock held.
+func (s *Server) seek(m *mediaSession, position float64) {
+	m.position = position
+	m.since = s.clock
+}
+
+func (s *Server) statusOf(m *mediaSession) *controllers.MediaStatus {
+	media, volume := m.media, m.volume
+	status := &controllers.MediaStatus{
+		MediaSessionID:         m.id,
+		PlaybackRate:           m.rate,
+		PlayerState:            m.playerState,
+		CurrentTime:            s.positionOf(m),
+		SupportedMediaCommands: supportedMediaCommands,
+		Volume:                 &volume,
+		Media:                  &media,
+		RepeatMode:             m.repeatMode,
+		IdleReason:             m.idleReason,
+		Items:                  append([]controllers.QueueItem(nil), m.queue...),
+		CurrentItemID:          m.currentId,
+		ActiveTrackIDs:         append([]int(nil), m.activeTracks...),
+	}
+	if status.IsLive() {
+		edge := s.liveEdge(m)
+		status.LiveSeekableRange = &controllers.LiveSeekableRange{End: edge}
+		status.IsPlayingLiveEdge = m.playerState == "PLAYING" && edge-status.CurrentTime < liveEdgeTolerance
+	}
+	return status
+}
+
+// mediaStatus builds a MEDIA_STATUS payload. Must be called with the lock
+// held.
+func (s *Server) mediaStatus(requestId *int) interface{} {
+	if s.media == nil {
+		return device.MediaStatus(requestId)
+	}
+	return device.MediaStatus(requestId, s.statusOf(s.media))
+}
+
+// mediaCommand handles a message on the media namespace. Must be called
+// with the lock held.
+func (s *Server) mediaCommand(p *device.Peer, message *api.CastMessage, headers *castnet.PayloadHeaders, payload []byte) {
+	switch headers.Type {
+	case "LOAD":
+		var request controllers.LoadMediaCommand
+		json.Unmarshal(payload, &request)
+		s.load(request.Media, float64(request.CurrentTime), request.Autoplay, request.ActiveTrackIDs)
+		s.device.Respond(p, message, headers, s.mediaStatus)
+		return
+	case "QUEUE_LOAD":
+		var request controllers.QueueLoadCommand
+		json.Unmarshal(payload, &request)
+		if len(request.Items) == 0 || request.StartIndex < 0 || request.StartIndex >= len(request.Items) {
+			p.Send(device.Reply(message, device.InvalidRequest(headers, "INVALID_PARAMS")))
+			return
+		}
+		s.queueLoad(&request)
+		s.device.Respond(p, message, headers, s.mediaStatus)
+		return
+	}
+	if headers.Type == "GET_STATUS" {
+		p.Send(device.Reply(message, s.mediaStatus(headers.RequestId)))
+		return
+	}
+
+	var request struct {
+		controllers.MediaCommand
+		CurrentTime  float64            `json:"currentTime"`
+		ResumeState  string             `json:"resumeState"`
+		PlaybackRate float64            `json:"playbackRate"`
+		Volume       controllers.Volume `json:"volume"`
+		controllers.QueueUpdateCommand
+		ActiveTrackIDs []int                       `json:"activeTrackIds"`
+		TextTrackStyle *controllers.TextTrackStyle `json:"textTrackStyle"`
+		Items          []controllers.QueueItem     `json:"items"`
+		ItemIDs        []int                       `json:"itemIds"`
+		InsertBefore   int                         `json:"insertBefore"`
+	}
+	json.Unmarshal(payload, &request)
+	m := s.media
+	if m == nil || request.MediaSessionID != m.id {
+		p.Send(device.Reply(message, device.InvalidRequest(headers, "INVALID_MEDIA_SESSION_ID")))
+		return
+	}
+
+	switch headers.Type {
+	case "PLAY":
+		if m.playerState == "PAUSED" {
+			s.seek(m, s.positionOf(m))
+			m.playerState = "PLAYING"
+		}
+	case "PAUSE":
+		if m.playerState == "PLAYING" {
+			s.seek(m, s.positionOf(m))
+			m.playerState = "PAUSED"
+		}
+	case "STOP":
+		s.seek(m, s.positionOf(m))
+		m.playerState = "IDLE"
+		m.idleReason = "CANCELLED"
+	case "SEEK":
+		position := request.CurrentTime
+		if m.media.StreamType == controllers.StreamTypeLive {
+			position = math.Max(0, math.Min(position, s.liveEdge(m)))
+		}
+		s.seek(m, position)
+		switch request.ResumeState {
+		case controllers.ResumeStatePlaybackStart:
+			m.playerState = "PLAYING"
+		case controllers.ResumeStatePlaybackPause:
+			m.playerState = "PAUSED"
+		}
+	case "SET_PLAYBACK_RATE":
+		s.seek(m, s.positionOf(m))
+		m.rate = request.PlaybackRate
+	case "SET_VOLUME":
+		if request.Volume.Level != nil {
+			m.volume.Level = request.Volume.Level
+		}
+		if request.Volume.Muted != nil {
+			m.volume.Muted = request.Volume.Muted
+		}
+	case "EDIT_TRACKS_INFO":
+		for _, id := range request.ActiveTrackIDs {
+			if !hasTrack(m.media.Tracks, id) {
+				p.Send(device.Reply(message, device.InvalidRequest(headers, "INVALID_TRACK_ID")))
+				return
+			}
+		}
+		if request.ActiveTrackIDs != nil {
+			m.activeTracks = request.ActiveTrackIDs
+		}
+		if request.TextTrackStyle != nil {
+			m.media.TextTrackStyle = request.TextTrackStyle
+		}
+	case "QUEUE_INSERT":
+		s.queueInsert(m, request.Items, request.InsertBefore)
+	case "QUEUE_REMOVE":
+		s.queueRemove(m, request.ItemIDs)
+	case "QUEUE_REORDER":
+		s.queueReorder(m, request.ItemIDs, request.InsertBefore)
+	case "QUEUE_UPDATE":
+		s.queueUpdate(m, &request.QueueUpdateCommand)
+	default:
+		p.Send(device.Reply(message, device.InvalidRequest(headers, "INVALID_COMMAND")))
+		return
+	}
+	s.device.Respond(p, message, headers, s.mediaStatus)
+}
+
+// load replaces the loaded media with a queue of just item. Must be called
+// with the lock held.
+func (s *Server) load(item controllers.MediaItem, currentTime float64, autoplay bool, activeTrackIds []int) {
+	s.queueLoad(&controllers.QueueLoadCommand{
+		Items: []controllers.QueueItem{{
+			Media:          item,
+			Autoplay:       autoplay,
+			StartTime:      currentTime,
+			ActiveTrackIDs: activeTrackIds,
+		}},
+	})
+}
+
+func hasTrack(tracks []controllers.MediaTrack, trackId int) bool {
+	for _, track := range tracks {
+		if track.TrackID == trackId {
+			return true
+		}
+	}
+	return false
+}
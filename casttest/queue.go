@@ -0,0 +1,185 @@
+package casttest
+
+import (
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/barnybug/go-cast/internal/device"
+)
+
+// queueLoad replaces the loaded media with a new queue. Must be called with
+// the lock held.
+func (s *Server) queueLoad(request *controllers.QueueLoadCommand) {
+	id := 1
+	if s.media != nil {
+		id = s.media.id + 1
+		s.interrupt(s.media)
+	}
+	level, muted := 1.0, false
+	m := &mediaSession{
+		id:         id,
+		rate:       1,
+		volume:     controllers.Volume{Level: &level, Muted: &muted},
+		repeatMode: request.RepeatMode,
+	}
+	if m.repeatMode == "" {
+		m.repeatMode = controllers.RepeatOff
+	}
+	s.queueInsert(m, request.Items, 0)
+	s.playItem(m, request.StartIndex)
+	if !request.Items[request.StartIndex].Autoplay {
+		m.playerState = "PAUSED"
+	}
+	s.media = m
+
+	statusText := "Now Casting: " + m.media.ContentId
+	s.device.App.StatusText = &statusText
+}
+
+// interrupt tells every sender that m has been replaced. Must be called
+// with the lock held.
+func (s *Server) interrupt(m *mediaSession) {
+	if m.playerState == "IDLE" {
+		return
+	}
+	s.seek(m, s.positionOf(m))
+	m.playerState = "IDLE"
+	m.idleReason = "INTERRUPTED"
+	s.device.BroadcastMedia(device.MediaStatus(nil, s.statusOf(m)))
+}
+
+// playItem starts playing the item at index from its start time. Must be
+// called with the lock held.
+func (s *Server) playItem(m *mediaSession, index int) {
+	item := m.queue[index]
+	m.currentId = item.ItemID
+	m.media = controllers.MediaStatusMedia{
+		ContentId:      item.Media.ContentId,
+		StreamType:     item.Media.StreamType,
+		ContentType:    item.Media.ContentType,
+		Duration:       s.duration[item.Media.ContentId],
+		Metadata:       item.Media.Metadata,
+		Tracks:         item.Media.Tracks,
+		TextTrackStyle: item.Media.TextTrackStyle,
+	}
+	m.activeTracks = item.ActiveTrackIDs
+	m.playerState = "PLAYING"
+	m.idleReason = ""
+	if item.Media.StreamType == controllers.StreamTypeLive {
+		// a live stream starts playing at its edge
+		m.liveSince = s.clock
+		s.seek(m, 0)
+		return
+	}
+	s.seek(m, item.StartTime)
+}
+
+// nextItem returns the index jump items on from the current one, following
+// the repeat mode. ended is true when the current item played to its end,
+// as opposed to the sender skipping it.
+func (s *Server) nextItem(m *mediaSession, jump int, ended bool) (int, bool) {
+	current := indexOf(m.queue, m.currentId)
+	if ended && m.repeatMode == controllers.RepeatSingle {
+		return current, current >= 0
+	}
+	next := current + jump
+	if m.repeatMode == controllers.RepeatAll || m.repeatMode == controllers.RepeatAllAndShuffle {
+		next = (next%len(m.queue) + len(m.queue)) % len(m.queue)
+	}
+	return next, next >= 0 && next < len(m.queue)
+}
+
+func indexOf(items []controllers.QueueItem, itemId int) int {
+	for i, item := range items {
+		if item.ItemID == itemId {
+			return i
+		}
+	}
+	return -1
+}
+
+// queueInsert gives items ids and inserts them before the item with id
+// insertBefore, or at the end. Must be called with the lock held.
+func (s *Server) queueInsert(m *mediaSession, items []controllers.QueueItem, insertBefore int) {
+	added := make([]controllers.QueueItem, len(items))
+	for i, item := range items {
+		m.lastId++
+		item.ItemID = m.lastId
+		added[i] = item
+	}
+	m.queue = insert(m.queue, added, insertBefore)
+}
+
+func insert(queue, items []controllers.QueueItem, insertBefore int) []controllers.QueueItem {
+	at := indexOf(queue, insertBefore)
+	if at < 0 {
+		at = len(queue)
+	}
+	result := make([]controllers.QueueItem, 0, len(queue)+len(items))
+	result = append(result, queue[:at]...)
+	result = append(result, items...)
+	return append(result, queue[at:]...)
+}
+
+// remove takes the items with itemIds out of queue, returning the rest and
+// the removed items in the order of itemIds.
+func remove(queue []controllers.QueueItem, itemIds []int) (rest, removed []controllers.QueueItem) {
+	for _, id := range itemIds {
+		if i := indexOf(queue, id); i >= 0 {
+			removed = append(removed, queue[i])
+		}
+	}
+	for _, item := range queue {
+		if indexOf(removed, item.ItemID) < 0 {
+			rest = append(rest, item)
+		}
+	}
+	return rest, removed
+}
+
+// queueRemove removes items from the queue, moving on to the item after
+// the current one if it is removed. Must be called with the lock held.
+func (s *Server) queueRemove(m *mediaSession, itemIds []int) {
+	current := indexOf(m.queue, m.currentId)
+	rest, _ := remove(m.queue, itemIds)
+	// the first remaining item at or after the current one
+	next := -1
+	for i := current; i < len(m.queue) && next < 0; i++ {
+		next = indexOf(rest, m.queue[i].ItemID)
+	}
+	m.queue = rest
+
+	if indexOf(m.queue, m.currentId) >= 0 {
+		return
+	}
+	if next < 0 {
+		s.seek(m, s.positionOf(m))
+		m.playerState = "IDLE"
+		m.idleReason = "FINISHED"
+		return
+	}
+	s.playItem(m, next)
+}
+
+// queueReorder moves items before the item with id insertBefore, or to the
+// end. Must be called with the lock held.
+func (s *Server) queueReorder(m *mediaSession, itemIds []int, insertBefore int) {
+	rest, moved := remove(m.queue, itemIds)
+	m.queue = insert(rest, moved, insertBefore)
+}
+
+// queueUpdate jumps within the queue and changes its repeat mode. Must be
+// called with the lock held.
+func (s *Server) queueUpdate(m *mediaSession, update *controllers.QueueUpdateCommand) {
+	if update.RepeatMode != "" {
+		m.repeatMode = update.RepeatMode
+	}
+	if update.CurrentItemID != 0 {
+		if i := indexOf(m.queue, update.CurrentItemID); i >= 0 {
+			s.playItem(m, i)
+		}
+	}
+	if update.Jump != 0 {
+		if next, ok := s.nextItem(m, update.Jump, false); ok {
+			s.playItem(m, next)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package casttest provides an in-process fake Chromecast for end to end
+// testing of Cast senders.
+//
+// The fake listens on a local TLS port and implements the platform
+// namespaces (connection, heartbeat and receiver) and the default media
+// receiver's media namespace. Media playback follows a simulated clock that
+// only moves when the test calls Advance, so tests are deterministic.
+package casttest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/barnybug/go-cast"
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/barnybug/go-cast/internal/device"
+	castnet "github.com/barnybug/go-cast/net"
+	"github.com/barnybug/go-cast/receiver"
+)
+
+// Server is a fake Chromecast.
+type Server struct {
+	listener net.Listener
+	device   *device.Device
+
+	// guarded by the device lock
+	media    *mediaSession
+	clock    time.Duration
+	duration map[string]float64
+}
+
+// NewServer starts a fake Chromecast listening on a random local port. It
+// panics if it cannot listen, like httptest.NewServer.
+func NewServer() *Server {
+	certificate, err := receiver.GenerateCertificate("casttest")
+	if err != nil {
+		panic(fmt.Sprintf("casttest: failed to create certificate: %s", err))
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		panic(fmt.Sprintf("casttest: failed to listen: %s", err))
+	}
+
+	s := &Server{
+		listener: listener,
+		duration: make(map[string]float64),
+	}
+	s.device = device.New(handler{s})
+	go s.device.Serve(listener)
+	return s
+}
+
+func (s *Server) IP() net.IP {
+	return s.listener.Addr().(*net.TCPAddr).IP
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Client returns a new, unconnected client for the server.
+func (s *Server) Client() *cast.Client {
+	return cast.NewClient(s.IP(), s.Port())
+}
+
+// Close stops the server and disconnects every sender.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.device.Close()
+}
+
+// Disconnect drops every connected sender, as if the network failed. The
+// server keeps listening and its state, including running apps, survives.
+func (s *Server) Disconnect() {
+	s.device.Disconnect()
+}
+
+// Volume returns the device volume.
+func (s *Server) Volume() (level float64, muted bool) {
+	s.device.Lock()
+	defer s.device.Unlock()
+	return *s.device.Volume.Level, *s.device.Volume.Muted
+}
+
+// App returns the id of the running app, or "" if none is running.
+func (s *Server) App() string {
+	s.device.Lock()
+	defer s.device.Unlock()
+	if s.device.App == nil {
+		return ""
+	}
+	return *s.device.App.AppID
+}
+
+// handler is the Server's side of its device.
+type handler struct {
+	*Server
+}
+
+// Launch starts any app, replacing the loaded media.
+func (h handler) Launch(app *controllers.ApplicationSession) error {
+	h.media = nil
+	return nil
+}
+
+func (h handler) Quit(app *controllers.ApplicationSession) error {
+	h.media = nil
+	return nil
+}
+
+func (h handler) SetVolume(volume controllers.Volume) error {
+	return nil
+}
+
+func (h handler) Media(p *device.Peer, message *api.CastMessage, headers *castnet.PayloadHeaders, payload []byte) {
+	h.mediaCommand(p, message, headers, payload)
+}
@@ -1,9 +1,12 @@
 package cast
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 
 	"golang.org/x/net/context"
 
@@ -27,9 +30,29 @@ type Client struct {
 	media      *controllers.MediaController
 	url        *controllers.URLController
 
+	// the app media is bound to, which Attach may have found running
+	mediaApp string
+
+	// virtual connections to the transports of launched apps
+	mediaConnection *controllers.ConnectionController
+	urlConnection   *controllers.ConnectionController
+
+	reconnect *ReconnectPolicy
+	authRoots *x509.CertPool
+	dialer    castnet.Dialer
+	tlsConfig *tls.Config
+	lock      sync.Mutex
+
+	interceptors []clientInterceptor
+
 	Events chan events.Event
 }
 
+type clientInterceptor struct {
+	direction   castnet.Direction
+	interceptor castnet.Interceptor
+}
+
 const DefaultSender = "sender-0"
 const DefaultReceiver = "receiver-0"
 const TransportSender = "Tr@n$p0rt-0"
@@ -80,9 +103,51 @@ func (c *Client) String() string {
 	return fmt.Sprintf("%s - %s:%d", c.name, c.host, c.port)
 }
 
+// SetAuthentication makes Connect verify that the device is a genuine Cast
+// device, with a certificate issued under roots. Pass nil to skip it.
+func (c *Client) SetAuthentication(roots *x509.CertPool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.authRoots = roots
+}
+
+// SetDialer replaces how the client opens its transport to the device, for
+// example to go through a proxy or tunnel. The dialer must return a
+// connection that has already completed any TLS handshake; wrap it with
+// castnet.TLSDialer to keep TLS.
+func (c *Client) SetDialer(dialer castnet.Dialer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.dialer = dialer
+}
+
+// SetTLSConfig sets the TLS configuration used by the default dialer.
+func (c *Client) SetTLSConfig(config *tls.Config) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tlsConfig = config
+}
+
+// AddInterceptor registers interceptor on the client's connection, see
+// castnet.Connection.AddInterceptor. Interceptors added before Connect see
+// the whole session.
+func (c *Client) AddInterceptor(direction castnet.Direction, interceptor castnet.Interceptor) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.interceptors = append(c.interceptors, clientInterceptor{direction, interceptor})
+	if c.conn != nil {
+		c.conn.AddInterceptor(direction, interceptor)
+	}
+}
+
 func (c *Client) Connect(ctx context.Context) error {
 	c.conn = castnet.NewConnection()
-	err := c.conn.Connect(ctx, c.host, c.port)
+	c.conn.Dialer = c.dialer
+	c.conn.TLSConfig = c.tlsConfig
+	for _, i := range c.interceptors {
+		c.conn.AddInterceptor(i.direction, i.interceptor)
+	}
+	err := c.dial(ctx)
 	if err != nil {
 		return err
 	}
@@ -104,21 +169,104 @@ func (c *Client) Connect(ctx context.Context) error {
 
 	// start receiver
 	c.receiver = controllers.NewReceiverController(c.conn, c.Events, DefaultSender, DefaultReceiver)
+	c.receiver.OnAppStopped(c.onAppStopped)
 	if err := c.receiver.Start(ctx); err != nil {
 		return err
 	}
 
+	go c.monitor(ctx, c.conn)
+
 	c.Events <- events.Connected{}
 
 	return nil
 }
 
+// dial connects to the device, authenticating it if configured to.
+func (c *Client) dial(ctx context.Context) error {
+	err := c.conn.Connect(ctx, c.host, c.port)
+	if err != nil {
+		return err
+	}
+	if c.authRoots != nil {
+		if err := c.conn.Authenticate(ctx, c.authRoots); err != nil {
+			c.conn.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// monitor reports the connection terminating underneath the client, and
+// re-establishes it if a reconnect policy is set. A deliberate Close cancels
+// ctx first, so it is not reported.
+func (c *Client) monitor(ctx context.Context, conn *castnet.Connection) {
+	for {
+		select {
+		case <-conn.Done():
+		case <-ctx.Done():
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		reason := conn.Err()
+		c.lock.Lock()
+		policy := c.reconnect
+		c.lock.Unlock()
+		if policy != nil {
+			reason = c.reconnectLoop(ctx, policy, reason)
+			if reason == nil {
+				continue
+			}
+		}
+
+		c.sendEvent(ctx, events.Disconnected{Reason: reason})
+		return
+	}
+}
+
+func (c *Client) sendEvent(ctx context.Context, event events.Event) {
+	select {
+	case c.Events <- event:
+	case <-ctx.Done():
+	}
+}
+
+func (c *Client) onAppStopped(app *controllers.ApplicationSession) {
+	if app.TransportId == nil {
+		return
+	}
+	// this runs on the receive goroutine, which a caller holding the lock
+	// may be waiting on
+	go c.release(*app.TransportId)
+}
+
+// release drops the controllers bound to an app session that has ended,
+// so that their channels stop receiving and the next call to Media or URL
+// launches the app afresh.
+func (c *Client) release(transportId string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.media != nil && c.media.DestinationID == transportId {
+		c.unbindMedia()
+	}
+	if c.url != nil && c.url.DestinationID == transportId {
+		c.url.Close()
+		c.urlConnection.Close()
+		c.url = nil
+		c.urlConnection = nil
+	}
+}
+
 func (c *Client) NewChannel(sourceId, destinationId, namespace string) *castnet.Channel {
 	return c.conn.NewChannel(sourceId, destinationId, namespace)
 }
 
 func (c *Client) Close() {
 	c.cancel()
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	if c.conn != nil {
 		c.conn.Close()
 		c.conn = nil
@@ -176,31 +324,92 @@ func (c *Client) IsPlaying(ctx context.Context) bool {
 }
 
 func (c *Client) Media(ctx context.Context) (*controllers.MediaController, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.media != nil && c.mediaApp != AppMedia {
+		// attached to another app, which may not take our loads
+		c.unbindMedia()
+	}
 	if c.media == nil {
 		transportId, err := c.launchMediaApp(ctx)
 		if err != nil {
 			return nil, err
 		}
-		conn := controllers.NewConnectionController(c.conn, c.Events, DefaultSender, transportId)
-		if err := conn.Start(ctx); err != nil {
+		if err := c.bindMedia(ctx, AppMedia, transportId); err != nil {
 			return nil, err
 		}
-		c.media = controllers.NewMediaController(c.conn, c.Events, DefaultSender, transportId)
-		if err := c.media.Start(ctx); err != nil {
+	}
+	return c.media, nil
+}
+
+// ErrNoMediaSession is returned by Attach when nothing is playing media.
+var ErrNoMediaSession = errors.New("No media session to attach to")
+
+// Attach returns a MediaController for the media already playing in
+// whichever app is running, such as one started from a phone, bound to
+// its current media session. Unlike Media, it never launches an app.
+func (c *Client) Attach(ctx context.Context) (*controllers.MediaController, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	status, err := c.receiver.GetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	app := status.GetSessionByNamespace(controllers.NamespaceMedia)
+	if app == nil || app.TransportId == nil {
+		return nil, ErrNoMediaSession
+	}
+	if c.media == nil || c.media.DestinationID != *app.TransportId {
+		if c.media != nil {
+			c.unbindMedia()
+		}
+		if err := c.bindMedia(ctx, *app.AppID, *app.TransportId); err != nil {
 			return nil, err
 		}
+	} else if _, err := c.media.GetStatus(ctx); err != nil {
+		return nil, err
+	}
+	if len(c.media.Sessions()) == 0 {
+		return nil, ErrNoMediaSession
 	}
 	return c.media, nil
 }
 
+// bindMedia connects to the media namespace of appId on transportId. Must
+// be called with the lock held.
+func (c *Client) bindMedia(ctx context.Context, appId, transportId string) error {
+	connection := controllers.NewConnectionController(c.conn, c.Events, DefaultSender, transportId)
+	if err := connection.Start(ctx); err != nil {
+		return err
+	}
+	media := controllers.NewMediaController(c.conn, c.Events, DefaultSender, transportId)
+	if err := media.Start(ctx); err != nil {
+		media.Close()
+		connection.Close()
+		return err
+	}
+	c.media, c.mediaConnection, c.mediaApp = media, connection, appId
+	return nil
+}
+
+// unbindMedia closes the media controller and its connection. Must be called
+// with the lock held.
+func (c *Client) unbindMedia() {
+	c.media.Close()
+	c.mediaConnection.Close()
+	c.media, c.mediaConnection, c.mediaApp = nil, nil, ""
+}
+
 func (c *Client) URL(ctx context.Context) (*controllers.URLController, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	if c.url == nil {
 		transportId, err := c.launchURLApp(ctx)
 		if err != nil {
 			return nil, err
 		}
-		conn := controllers.NewConnectionController(c.conn, c.Events, DefaultSender, transportId)
-		if err := conn.Start(ctx); err != nil {
+		c.urlConnection = controllers.NewConnectionController(c.conn, c.Events, DefaultSender, transportId)
+		if err := c.urlConnection.Start(ctx); err != nil {
 			return nil, err
 		}
 		c.url = controllers.NewURLController(c.conn, c.Events, DefaultSender, transportId)
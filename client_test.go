@@ -0,0 +1,620 @@
+package cast_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast"
+	"github.com/barnybug/go-cast/casttest"
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/barnybug/go-cast/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func connect(t *testing.T, server *casttest.Server) (*cast.Client, context.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	client := server.Client()
+	require.NoError(t, client.Connect(ctx))
+	t.Cleanup(client.Close)
+	return client, ctx
+}
+
+func mediaStatus(t *testing.T, payload *string) *controllers.MediaStatus {
+	response := &controllers.MediaStatusResponse{}
+	require.NoError(t, json.Unmarshal([]byte(*payload), response))
+	require.Len(t, response.Status, 1)
+	return response.Status[0]
+}
+
+func TestClientConnect(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+
+	assert.Equal(t, events.Connected{}, <-client.Events)
+
+	status, err := client.Receiver().GetStatus(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, status.Applications)
+	assert.False(t, client.IsPlaying(ctx))
+}
+
+func TestClientMediaPlayback(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	server.SetDuration("http://example.com/video.mp4", time.Minute)
+	client, ctx := connect(t, server)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, cast.AppMedia, server.App())
+
+	item := controllers.MediaItem{
+		ContentId:   "http://example.com/video.mp4",
+		StreamType:  "BUFFERED",
+		ContentType: "video/mp4",
+		Metadata: &controllers.MediaMetadata{
+			MetadataType: controllers.MetadataMovie,
+			Title:        "Big Buck Bunny",
+			Studio:       "Blender Foundation",
+			Images:       []controllers.MediaImage{{URL: "http://example.com/poster.jpg"}},
+		},
+	}
+	message, err := media.LoadMedia(ctx, item, 5, true, nil)
+	require.NoError(t, err)
+	status := mediaStatus(t, message.PayloadUtf8)
+	assert.Equal(t, "PLAYING", status.PlayerState)
+	assert.Equal(t, 60.0, status.Media.Duration)
+	assert.Equal(t, item.Metadata, status.Media.Metadata)
+	assert.True(t, client.IsPlaying(ctx))
+
+	server.Advance(10 * time.Second)
+	message, err = media.Pause(ctx)
+	require.NoError(t, err)
+	status = mediaStatus(t, message.PayloadUtf8)
+	assert.Equal(t, "PAUSED", status.PlayerState)
+	assert.Equal(t, 15.0, status.CurrentTime)
+
+	// the clock does not move playback while paused
+	server.Advance(time.Hour)
+	message, err = media.Play(ctx)
+	require.NoError(t, err)
+	status = mediaStatus(t, message.PayloadUtf8)
+	assert.Equal(t, "PLAYING", status.PlayerState)
+	assert.Equal(t, 15.0, status.CurrentTime)
+
+	server.Advance(time.Hour)
+	status = server.MediaStatus()
+	assert.Equal(t, "IDLE", status.PlayerState)
+	assert.Equal(t, "FINISHED", status.IdleReason)
+	assert.Equal(t, 60.0, status.CurrentTime)
+}
+
+func TestClientVolumeAndQuit(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+
+	level, muted := 0.25, true
+	_, err := client.Receiver().SetVolume(ctx, &controllers.Volume{Level: &level})
+	require.NoError(t, err)
+	_, err = client.Receiver().SetVolume(ctx, &controllers.Volume{Muted: &muted})
+	require.NoError(t, err)
+
+	level, muted = server.Volume()
+	assert.Equal(t, 0.25, level)
+	assert.True(t, muted)
+
+	_, err = client.Media(ctx)
+	require.NoError(t, err)
+	_, err = client.Receiver().QuitApp(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "", server.App())
+}
+
+func TestClientReconnects(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+	client.SetReconnectPolicy(&cast.ReconnectPolicy{Backoff: 10 * time.Millisecond, Timeout: 5 * time.Second})
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	item := controllers.MediaItem{ContentId: "http://example.com/song.mp3", StreamType: "BUFFERED", ContentType: "audio/mpeg"}
+	_, err = media.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+
+	server.Disconnect()
+
+	for {
+		select {
+		case event := <-client.Events:
+			if _, ok := event.(events.Reconnected); !ok {
+				continue
+			}
+		case <-ctx.Done():
+			t.Fatal("client did not reconnect")
+		}
+		break
+	}
+
+	_, err = media.Pause(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "PAUSED", server.MediaStatus().PlayerState)
+}
+
+func TestClientReconnectBacksOff(t *testing.T) {
+	server := casttest.NewServer()
+	client, ctx := connect(t, server)
+	// a zero backoff falls back on the default rather than re-dialling at once
+	client.SetReconnectPolicy(&cast.ReconnectPolicy{})
+
+	server.Close()
+
+	attempts := 0
+	timeout := time.After(300 * time.Millisecond)
+	for done := false; !done; {
+		select {
+		case event := <-client.Events:
+			if _, ok := event.(events.Reconnecting); ok {
+				attempts++
+			}
+		case <-timeout:
+			done = true
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+	assert.Equal(t, 1, attempts)
+}
+
+func TestClientSeekAndRate(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	item := controllers.MediaItem{ContentId: "http://example.com/video.mp4", StreamType: "BUFFERED", ContentType: "video/mp4"}
+	_, err = media.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+
+	status, err := media.Seek(ctx, 30, controllers.ResumeStatePlaybackPause)
+	require.NoError(t, err)
+	assert.Equal(t, 30.0, status.CurrentTime)
+	assert.Equal(t, "PAUSED", status.PlayerState)
+
+	status, err = media.Seek(ctx, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, status.CurrentTime)
+	assert.Equal(t, "PAUSED", status.PlayerState)
+
+	status, err = media.SetPlaybackRate(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, status.PlaybackRate)
+
+	_, err = media.Play(ctx)
+	require.NoError(t, err)
+	server.Advance(5 * time.Second)
+	assert.Equal(t, 20.0, server.MediaStatus().CurrentTime)
+
+	status, err = media.SetStreamVolume(ctx, 0.5)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, *status.Volume.Level)
+	status, err = media.SetStreamMuted(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, *status.Volume.Level)
+	assert.True(t, *status.Volume.Muted)
+
+	// the device volume is separate from the stream's
+	level, muted := server.Volume()
+	assert.Equal(t, 1.0, level)
+	assert.False(t, muted)
+}
+
+func queueItems(contentIds ...string) []controllers.QueueItem {
+	items := make([]controllers.QueueItem, len(contentIds))
+	for i, contentId := range contentIds {
+		items[i] = controllers.QueueItem{
+			Media:    controllers.MediaItem{ContentId: contentId, StreamType: "BUFFERED", ContentType: "audio/mpeg"},
+			Autoplay: true,
+		}
+	}
+	return items
+}
+
+func itemIds(status *controllers.MediaStatus) []int {
+	ids := make([]int, len(status.Items))
+	for i, item := range status.Items {
+		ids[i] = item.ItemID
+	}
+	return ids
+}
+
+func TestClientQueue(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	status, err := media.QueueLoad(ctx, queueItems("a", "b", "c"), 1, controllers.RepeatAll)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, itemIds(status))
+	assert.Equal(t, 2, status.CurrentItemID)
+	assert.Equal(t, "b", status.Media.ContentId)
+	assert.Equal(t, controllers.RepeatAll, status.RepeatMode)
+	assert.Equal(t, "PLAYING", status.PlayerState)
+
+	status, err = media.QueueInsert(ctx, queueItems("d"), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 4, 2, 3}, itemIds(status))
+	status, err = media.QueueInsert(ctx, queueItems("e"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 4, 2, 3, 5}, itemIds(status))
+
+	status, err = media.QueueReorder(ctx, []int{5, 1}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4, 5, 1, 2, 3}, itemIds(status))
+	assert.Equal(t, 2, status.CurrentItemID)
+
+	// removing the current item moves on to the next
+	status, err = media.QueueRemove(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4, 5, 1, 3}, itemIds(status))
+	assert.Equal(t, 3, status.CurrentItemID)
+	assert.Equal(t, "c", status.Media.ContentId)
+
+	// without repeat, next and prev stop at the ends
+	status, err = media.SetRepeatMode(ctx, controllers.RepeatOff)
+	require.NoError(t, err)
+	assert.Equal(t, controllers.RepeatOff, status.RepeatMode)
+	status, err = media.QueueNext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, status.CurrentItemID)
+	status, err = media.QueuePrev(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.CurrentItemID)
+	status, err = media.QueueJump(ctx, 4)
+	require.NoError(t, err)
+	assert.Equal(t, 4, status.CurrentItemID)
+	status, err = media.QueuePrev(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, status.CurrentItemID)
+
+	// with repeat, they wrap around
+	_, err = media.SetRepeatMode(ctx, controllers.RepeatAll)
+	require.NoError(t, err)
+	status, err = media.QueuePrev(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, status.CurrentItemID)
+	status, err = media.QueueNext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, status.CurrentItemID)
+	assert.Equal(t, "d", status.Media.ContentId)
+}
+
+func TestClientQueueAdvances(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	server.SetDuration("a", 10*time.Second)
+	server.SetDuration("b", 10*time.Second)
+	_, err = media.QueueLoad(ctx, queueItems("a", "b"), 0, controllers.RepeatOff)
+	require.NoError(t, err)
+
+	server.Advance(15 * time.Second)
+	status := server.MediaStatus()
+	assert.Equal(t, 2, status.CurrentItemID)
+	assert.Equal(t, "b", status.Media.ContentId)
+	assert.Equal(t, 5.0, status.CurrentTime)
+	assert.Equal(t, "PLAYING", status.PlayerState)
+
+	server.Advance(10 * time.Second)
+	status = server.MediaStatus()
+	assert.Equal(t, 2, status.CurrentItemID)
+	assert.Equal(t, "IDLE", status.PlayerState)
+	assert.Equal(t, "FINISHED", status.IdleReason)
+
+	// the device tells senders as it moves on
+	reason, err := media.WaitForIdle(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "FINISHED", reason)
+}
+
+func TestClientTracks(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	item := controllers.MediaItem{
+		ContentId:   "http://example.com/film.mp4",
+		StreamType:  "BUFFERED",
+		ContentType: "video/mp4",
+		Tracks: []controllers.MediaTrack{
+			{TrackID: 1, Type: controllers.TrackText, Subtype: "SUBTITLES", TrackContentId: "http://example.com/en.vtt", TrackContentType: "text/vtt", Language: "en"},
+			{TrackID: 2, Type: controllers.TrackText, Subtype: "SUBTITLES", TrackContentId: "http://example.com/fr.vtt", TrackContentType: "text/vtt", Language: "fr"},
+			{TrackID: 3, Type: controllers.TrackAudio, Language: "en"},
+		},
+	}
+	message, err := media.LoadMedia(ctx, item, 0, true, nil, 1, 3)
+	require.NoError(t, err)
+	status := mediaStatus(t, message.PayloadUtf8)
+	assert.Equal(t, []int{1, 3}, status.ActiveTrackIDs)
+	assert.Equal(t, item.Tracks, status.Media.Tracks)
+
+	style := &controllers.TextTrackStyle{ForegroundColor: "#FFFF00FF", FontScale: 1.5}
+	status, err = media.EditTracksInfo(ctx, []int{2, 3}, style)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, status.ActiveTrackIDs)
+	assert.Equal(t, style, status.Media.TextTrackStyle)
+
+	status, err = media.EditTracksInfo(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, status.ActiveTrackIDs)
+
+	_, err = media.EditTracksInfo(ctx, []int{9}, nil)
+	assert.Error(t, err)
+}
+
+func TestClientLive(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	item := controllers.MediaItem{ContentId: "http://example.com/radio", StreamType: controllers.StreamTypeLive, ContentType: "audio/mpeg"}
+	_, err = media.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+
+	server.Advance(time.Minute)
+	response, err := media.GetStatus(ctx)
+	require.NoError(t, err)
+	status := response.Status[0]
+	assert.True(t, status.IsLive())
+	assert.Equal(t, &controllers.LiveSeekableRange{Start: 0, End: 60}, status.LiveSeekableRange)
+	assert.Equal(t, 60.0, status.CurrentTime)
+	assert.True(t, status.IsPlayingLiveEdge)
+
+	status, err = media.SeekFromLiveEdge(ctx, 30, "")
+	require.NoError(t, err)
+	assert.Equal(t, 30.0, status.CurrentTime)
+	assert.False(t, status.IsPlayingLiveEdge)
+
+	// no further back than the start of the range
+	status, err = media.SeekFromLiveEdge(ctx, 600, "")
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, status.CurrentTime)
+
+	item = controllers.MediaItem{ContentId: "http://example.com/song.mp3", StreamType: controllers.StreamTypeBuffered, ContentType: "audio/mpeg"}
+	_, err = media.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+	_, err = media.SeekFromLiveEdge(ctx, 30, "")
+	assert.Error(t, err)
+}
+
+func TestClientWait(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+	other, _ := connect(t, server)
+
+	// another sender launching the app
+	go other.Media(ctx)
+	app, err := client.Receiver().WaitForApp(ctx, cast.AppMedia)
+	require.NoError(t, err)
+	assert.Equal(t, "Default Media Receiver", *app.DisplayName)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	server.SetDuration("http://example.com/song.mp3", 10*time.Second)
+	item := controllers.MediaItem{ContentId: "http://example.com/song.mp3", StreamType: "BUFFERED", ContentType: "audio/mpeg"}
+	_, err = media.LoadMedia(ctx, item, 0, false, nil)
+	require.NoError(t, err)
+	status, err := media.WaitForState(ctx, "PLAYING", "PAUSED")
+	require.NoError(t, err)
+	assert.Equal(t, "PAUSED", status.PlayerState)
+
+	_, err = media.Play(ctx)
+	require.NoError(t, err)
+	go server.Advance(time.Minute)
+	reason, err := media.WaitForIdle(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "FINISHED", reason)
+
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = client.Receiver().WaitForApp(timeout, cast.AppURL)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestClientSessions(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+	other, _ := connect(t, server)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	item := controllers.MediaItem{ContentId: "http://example.com/song.mp3", StreamType: "BUFFERED", ContentType: "audio/mpeg"}
+	_, err = media.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+	first := media.Sessions()[0].MediaSessionID
+
+	// another sender replaces the media
+	otherMedia, err := other.Media(ctx)
+	require.NoError(t, err)
+	_, err = otherMedia.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+	var sessions []*controllers.MediaStatus
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if sessions = media.Sessions(); len(sessions) == 1 && sessions[0].MediaSessionID != first {
+			break
+		}
+	}
+	require.Len(t, sessions, 1)
+	second := sessions[0].MediaSessionID
+	require.NotEqual(t, first, second)
+
+	_, err = media.Session(second).Pause(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "PAUSED", server.MediaStatus().PlayerState)
+
+	_, err = media.Session(first).Play(ctx)
+	var responseErr *controllers.ResponseError
+	require.True(t, errors.As(err, &responseErr))
+	assert.Equal(t, "INVALID_MEDIA_SESSION_ID", responseErr.Reason)
+}
+
+func TestClientAttach(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+	other, _ := connect(t, server)
+
+	_, err := client.Attach(ctx)
+	assert.Equal(t, cast.ErrNoMediaSession, err)
+
+	// another sender starts playing
+	otherMedia, err := other.Media(ctx)
+	require.NoError(t, err)
+	item := controllers.MediaItem{ContentId: "http://example.com/song.mp3", StreamType: "BUFFERED", ContentType: "audio/mpeg"}
+	_, err = otherMedia.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+
+	media, err := client.Attach(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, server.MediaStatus().MediaSessionID, media.Sessions()[0].MediaSessionID)
+	_, err = media.Pause(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "PAUSED", server.MediaStatus().PlayerState)
+
+	same, err := client.Media(ctx)
+	require.NoError(t, err)
+	assert.True(t, media == same)
+}
+
+func TestClientMediaRelaunched(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+	other, _ := connect(t, server)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+
+	// another sender relaunches the app, in a new session on a new transport
+	_, err = other.Receiver().LaunchApp(ctx, cast.AppMedia)
+	require.NoError(t, err)
+	for {
+		select {
+		case event := <-client.Events:
+			if _, ok := event.(events.AppStopped); !ok {
+				continue
+			}
+		case <-ctx.Done():
+			t.Fatal("app was not reported stopped")
+		}
+		break
+	}
+	// the old controller is released in the background
+	for {
+		relaunched, err := client.Media(ctx)
+		require.NoError(t, err)
+		if relaunched != media {
+			media = relaunched
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	item := controllers.MediaItem{ContentId: "http://example.com/song.mp3", StreamType: "BUFFERED", ContentType: "audio/mpeg"}
+	_, err = media.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "PLAYING", server.MediaStatus().PlayerState)
+}
+
+func TestClientAttachOtherApp(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+	client.SetReconnectPolicy(&cast.ReconnectPolicy{Backoff: 10 * time.Millisecond, Timeout: 5 * time.Second})
+
+	// a phone casts from its own app
+	item := controllers.MediaItem{ContentId: "spotify:track:1", StreamType: "BUFFERED", ContentType: "audio/mpeg"}
+	server.Cast("CC32E753", item)
+
+	media, err := client.Attach(ctx)
+	require.NoError(t, err)
+
+	server.Disconnect()
+	for {
+		select {
+		case event := <-client.Events:
+			if _, ok := event.(events.Reconnected); !ok {
+				continue
+			}
+		case <-ctx.Done():
+			t.Fatal("client did not reconnect")
+		}
+		break
+	}
+
+	_, err = media.Pause(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "PAUSED", server.MediaStatus().PlayerState)
+	assert.Equal(t, "CC32E753", server.App())
+}
+
+func TestClientMediaAfterAttachOtherApp(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+
+	// a phone casts from its own app, which the client pauses
+	server.Cast("CC32E753", controllers.MediaItem{ContentId: "spotify:track:1", StreamType: "BUFFERED", ContentType: "audio/mpeg"})
+	attached, err := client.Attach(ctx)
+	require.NoError(t, err)
+
+	// then plays something of its own
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	assert.False(t, media == attached)
+	assert.Equal(t, cast.AppMedia, server.App())
+
+	item := controllers.MediaItem{ContentId: "http://example.com/song.mp3", StreamType: "BUFFERED", ContentType: "audio/mpeg"}
+	_, err = media.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "PLAYING", server.MediaStatus().PlayerState)
+	assert.Equal(t, item.ContentId, server.MediaStatus().Media.ContentId)
+}
+
+func TestClientResponseError(t *testing.T) {
+	server := casttest.NewServer()
+	defer server.Close()
+	client, ctx := connect(t, server)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+
+	// nothing is loaded yet
+	_, err = media.Play(ctx)
+	var responseErr *controllers.ResponseError
+	require.True(t, errors.As(err, &responseErr), "%v", err)
+	assert.Equal(t, "INVALID_REQUEST", responseErr.Type)
+	assert.Equal(t, "INVALID_MEDIA_SESSION_ID", responseErr.Reason)
+	assert.Contains(t, responseErr.Payload, "INVALID_MEDIA_SESSION_ID")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"strconv"
@@ -69,6 +70,20 @@ func main() {
 					Usage:     "play some media",
 					ArgsUsage: "play url [content type]",
 					Action:    cliCommand,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "title",
+							Usage: "title to show while playing",
+						},
+						cli.StringFlag{
+							Name:  "image",
+							Usage: "url of artwork to show while playing",
+						},
+						cli.BoolFlag{
+							Name:  "live",
+							Usage: "play as a live stream, such as radio or IPTV",
+						},
+					},
 				},
 				{
 					Name:   "stop",
@@ -80,6 +95,29 @@ func main() {
 					Usage:  "pause playing media",
 					Action: cliCommand,
 				},
+				{
+					Name:   "resume",
+					Usage:  "resume paused media",
+					Action: cliCommand,
+				},
+				{
+					Name:      "seek",
+					Usage:     "seek to a position in seconds",
+					ArgsUsage: "seek position",
+					Action:    cliCommand,
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "live",
+							Usage: "seek to position seconds behind the edge of a live stream",
+						},
+					},
+				},
+				{
+					Name:      "rate",
+					Usage:     "set the playback rate, 1.0 being normal speed",
+					ArgsUsage: "rate rate",
+					Action:    cliCommand,
+				},
 			},
 		},
 		{
@@ -119,6 +157,18 @@ func main() {
 			Usage:  "Discover Chromecast devices",
 			Action: discoverCommand,
 		},
+		{
+			Name:   "proxy",
+			Usage:  "Relay and print the messages between a sender and the Chromecast",
+			Action: proxyCommand,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "listen",
+					Usage: "address to accept senders on",
+					Value: ":8009",
+				},
+			},
+		},
 		{
 			Name:   "watch",
 			Usage:  "Discover and watch  Chromecast devices for events",
@@ -137,10 +187,49 @@ func cliCommand(c *cli.Context) {
 		return
 	}
 	client := connect(ctx, c)
-	runCommand(ctx, client, c.Command.Name, c.Args())
+	runCommand(ctx, client, c.Command.Name, c.Args(), options{
+		metadata: metadata(c),
+		live:     c.Bool("live"),
+	})
+}
+
+// options are the flags given to a media command.
+type options struct {
+	metadata *controllers.MediaMetadata
+	live     bool
+}
+
+// metadata returns the metadata given by the --title and --image flags, or
+// nil if neither was given.
+func metadata(c *cli.Context) *controllers.MediaMetadata {
+	title, image := c.String("title"), c.String("image")
+	if title == "" && image == "" {
+		return nil
+	}
+	metadata := &controllers.MediaMetadata{
+		MetadataType: controllers.MetadataGeneric,
+		Title:        title,
+	}
+	if image != "" {
+		metadata.Images = []controllers.MediaImage{{URL: image}}
+	}
+	return metadata
 }
 
 func connect(ctx context.Context, c *cli.Context) *cast.Client {
+	client := find(ctx, c)
+
+	fmt.Printf("Connecting to %s:%d...\n", client.IP(), client.Port())
+	err := client.Connect(ctx)
+	checkErr(err)
+
+	fmt.Println("Connected")
+	return client
+}
+
+// find returns an unconnected client for the device given by --host or
+// --name.
+func find(ctx context.Context, c *cli.Context) *cast.Client {
 	host := c.GlobalString("host")
 	name := c.GlobalString("name")
 	if host == "" && name == "" {
@@ -148,42 +237,30 @@ func connect(ctx context.Context, c *cli.Context) *cast.Client {
 		os.Exit(1)
 	}
 
-	var client *cast.Client
 	if host != "" {
 		log.Printf("Looking up %s...", host)
 		ips, err := net.LookupIP(host)
 		checkErr(err)
 
-		client = cast.NewClient(ips[0], c.GlobalInt("port"))
-	} else {
-		// run discovery and stop once we have find this name
-		service := discovery.NewService(ctx)
-		go service.Run(ctx, 2*time.Second)
-
-	LOOP:
-		for {
-			select {
-			case c := <-service.Found():
-				if c.Name() == name {
-					log.Printf("Found: %s at %s:%d", c.Name(), c.IP(), c.Port())
-					client = c
-					break LOOP
-				}
-			case <-ctx.Done():
-				break LOOP
-			}
-		}
-
-		// check for timeout
-		checkErr(ctx.Err())
+		return cast.NewClient(ips[0], c.GlobalInt("port"))
 	}
 
-	fmt.Printf("Connecting to %s:%d...\n", client.IP(), client.Port())
-	err := client.Connect(ctx)
-	checkErr(err)
+	// run discovery and stop once we have find this name
+	service := discovery.NewService(ctx)
+	go service.Run(ctx, 2*time.Second)
 
-	fmt.Println("Connected")
-	return client
+	for {
+		select {
+		case c := <-service.Found():
+			if c.Name() == name {
+				log.Printf("Found: %s at %s:%d", c.Name(), c.IP(), c.Port())
+				return c
+			}
+		case <-ctx.Done():
+			// timed out
+			checkErr(ctx.Err())
+		}
+	}
 }
 
 func scriptCommand(c *cli.Context) {
@@ -207,7 +284,7 @@ func scriptCommand(c *cli.Context) {
 	client := connect(ctx, c)
 
 	for _, args := range commands {
-		runCommand(ctx, client, args[0], args[1:])
+		runCommand(ctx, client, args[0], args[1:], options{})
 	}
 }
 
@@ -227,6 +304,14 @@ func statusCommand(c *cli.Context) {
 	} else {
 		fmt.Println("No applications running")
 	}
+	if media, err := client.Attach(ctx); err == nil {
+		for _, status := range media.Sessions() {
+			current, _ := media.Session(status.MediaSessionID).EstimatedPosition()
+			fmt.Printf("Media: %s %s\n", status.PlayerState, position(status, current))
+		}
+	} else if err != cast.ErrNoMediaSession {
+		checkErr(err)
+	}
 	fmt.Printf("Volume: %.2f", *status.Volume.Level)
 	if *status.Volume.Muted {
 		fmt.Print("muted\n")
@@ -235,6 +320,23 @@ func statusCommand(c *cli.Context) {
 	}
 }
 
+// position describes how far playback has got, current being the playback
+// position. A live stream has no duration, so is shown by how far it is
+// behind the live edge instead.
+func position(status *controllers.MediaStatus, current float64) string {
+	switch {
+	case status.IsLive():
+		if status.IsPlayingLiveEdge || status.LiveSeekableRange == nil {
+			return "live"
+		}
+		return fmt.Sprintf("live, %.1fs behind", status.LiveSeekableRange.End-status.CurrentTime)
+	case status.Media != nil && status.Media.Duration > 0:
+		return fmt.Sprintf("%.1fs/%.1fs", current, status.Media.Duration)
+	default:
+		return fmt.Sprintf("%.1fs", current)
+	}
+}
+
 func discoverCommand(c *cli.Context) {
 	log.Debug = c.GlobalBool("debug")
 	timeout := c.GlobalDuration("timeout")
@@ -263,32 +365,37 @@ func watchCommand(c *cli.Context) {
 	log.Debug = c.GlobalBool("debug")
 	timeout := c.GlobalDuration("timeout")
 
-CONNECT:
-	for {
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
-		client := connect(ctx, c)
-		client.Media(ctx)
-		cancel()
-
-		for event := range client.Events {
-			switch t := event.(type) {
-			case events.Connected:
-			case events.AppStarted:
-				fmt.Printf("App started: %s [%s]\n", t.DisplayName, t.AppID)
-			case events.AppStopped:
-				fmt.Printf("App stopped: %s [%s]\n", t.DisplayName, t.AppID)
-			case events.StatusUpdated:
-				fmt.Printf("Status updated: volume %.2f [%v]\n", t.Level, t.Muted)
-			case events.Disconnected:
-				fmt.Printf("Disconnected: %s\n", t.Reason)
-				fmt.Println("Reconnecting...")
-				client.Close()
-				continue CONNECT
-			case controllers.MediaStatus:
-				fmt.Printf("Media Status: state: %s %.1fs\n", t.PlayerState, t.CurrentTime)
-			default:
-				fmt.Printf("Unknown event: %#v\n", t)
-			}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	client := connect(ctx, c)
+	client.Media(ctx)
+	cancel()
+
+	policy := cast.DefaultReconnectPolicy
+	policy.Timeout = timeout
+	client.SetReconnectPolicy(&policy)
+
+	for event := range client.Events {
+		switch t := event.(type) {
+		case events.Connected:
+		case events.AppStarted:
+			fmt.Printf("App started: %s [%s]\n", t.DisplayName, t.AppID)
+		case events.AppStopped:
+			fmt.Printf("App stopped: %s [%s]\n", t.DisplayName, t.AppID)
+		case events.StatusUpdated:
+			fmt.Printf("Status updated: volume %.2f [%v]\n", t.Level, t.Muted)
+		case events.Reconnecting:
+			fmt.Printf("Disconnected: %s\n", t.Reason)
+			fmt.Printf("Reconnecting (attempt %d)...\n", t.Attempt)
+		case events.Reconnected:
+			fmt.Println("Reconnected")
+		case events.Disconnected:
+			fmt.Printf("Disconnected: %s\n", t.Reason)
+			client.Close()
+			return
+		case controllers.MediaStatus:
+			fmt.Printf("Media Status: state: %s %s\n", t.PlayerState, position(&t, t.CurrentTime))
+		default:
+			fmt.Printf("Unknown event: %#v\n", t)
 		}
 	}
 }
@@ -296,6 +403,9 @@ CONNECT:
 var minArgs = map[string]int{
 	"play":   1,
 	"pause":  0,
+	"resume": 0,
+	"seek":   1,
+	"rate":   1,
 	"stop":   0,
 	"quit":   0,
 	"volume": 1,
@@ -305,6 +415,9 @@ var minArgs = map[string]int{
 var maxArgs = map[string]int{
 	"play":   2,
 	"pause":  0,
+	"resume": 0,
+	"seek":   1,
+	"rate":   1,
 	"stop":   0,
 	"quit":   0,
 	"volume": 1,
@@ -330,7 +443,16 @@ func checkCommand(cmd string, args []string) bool {
 			fmt.Printf("Command '%s': %s\n", cmd, err)
 			return false
 		}
-
+	case "seek":
+		if err := validateFloat(args[0], 0.0, math.MaxFloat64); err != nil {
+			fmt.Printf("Command '%s': %s\n", cmd, err)
+			return false
+		}
+	case "rate":
+		if err := validateFloat(args[0], 0.5, 2.0); err != nil {
+			fmt.Printf("Command '%s': %s\n", cmd, err)
+			return false
+		}
 	}
 	return true
 }
@@ -338,18 +460,18 @@ func checkCommand(cmd string, args []string) bool {
 func validateFloat(val string, min, max float64) error {
 	fval, err := strconv.ParseFloat(val, 64)
 	if err != nil {
-		return fmt.Errorf("Expected a number between 0.0 and 1.0")
+		return fmt.Errorf("Expected a number")
 	}
 	if fval < min {
 		return fmt.Errorf("Value is below minimum: %.2f", min)
 	}
 	if fval > max {
-		return fmt.Errorf("Value is below maximum: %.2f", max)
+		return fmt.Errorf("Value is above maximum: %.2f", max)
 	}
 	return nil
 }
 
-func runCommand(ctx context.Context, client *cast.Client, cmd string, args []string) {
+func runCommand(ctx context.Context, client *cast.Client, cmd string, args []string, opts options) {
 	switch cmd {
 	case "play":
 		media, err := client.Media(ctx)
@@ -359,26 +481,55 @@ func runCommand(ctx context.Context, client *cast.Client, cmd string, args []str
 		if len(args) > 1 {
 			contentType = args[1]
 		}
+		streamType := controllers.StreamTypeBuffered
+		if opts.live {
+			streamType = controllers.StreamTypeLive
+		}
 		item := controllers.MediaItem{
 			ContentId:   url,
-			StreamType:  "BUFFERED",
+			StreamType:  streamType,
 			ContentType: contentType,
+			Metadata:    opts.metadata,
 		}
 		_, err = media.LoadMedia(ctx, item, 0, true, map[string]interface{}{})
 		checkErr(err)
 
 	case "pause":
-		media, err := client.Media(ctx)
+		media, err := client.Attach(ctx)
 		checkErr(err)
 		_, err = media.Pause(ctx)
 		checkErr(err)
 
+	case "resume":
+		media, err := client.Attach(ctx)
+		checkErr(err)
+		_, err = media.Play(ctx)
+		checkErr(err)
+
+	case "seek":
+		media, err := client.Attach(ctx)
+		checkErr(err)
+		position, _ := strconv.ParseFloat(args[0], 64)
+		if opts.live {
+			_, err = media.SeekFromLiveEdge(ctx, position, "")
+		} else {
+			_, err = media.Seek(ctx, position, "")
+		}
+		checkErr(err)
+
+	case "rate":
+		media, err := client.Attach(ctx)
+		checkErr(err)
+		rate, _ := strconv.ParseFloat(args[0], 64)
+		_, err = media.SetPlaybackRate(ctx, rate)
+		checkErr(err)
+
 	case "stop":
-		if !client.IsPlaying(ctx) {
-			// if media isn't running, no media can be playing
+		media, err := client.Attach(ctx)
+		if err == cast.ErrNoMediaSession {
+			// no media is playing
 			return
 		}
-		media, err := client.Media(ctx)
 		checkErr(err)
 		_, err = media.Stop(ctx)
 		checkErr(err)
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/log"
+	castnet "github.com/barnybug/go-cast/net"
+	"github.com/barnybug/go-cast/receiver"
+	"github.com/urfave/cli"
+)
+
+// proxyCommand accepts senders as if it were the Chromecast, relaying every
+// frame to the real device and printing it on the way. Senders that
+// authenticate the device will notice they are not talking to it directly.
+func proxyCommand(c *cli.Context) {
+	log.Debug = c.GlobalBool("debug")
+	timeout := c.GlobalDuration("timeout")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	device := find(ctx, c)
+	cancel()
+
+	certificate, err := receiver.GenerateCertificate("cast proxy")
+	checkErr(err)
+	listener, err := tls.Listen("tcp", c.String("listen"), &tls.Config{Certificates: []tls.Certificate{certificate}})
+	checkErr(err)
+	defer listener.Close()
+
+	fmt.Printf("Proxying %s to %s:%d...\n", listener.Addr(), device.IP(), device.Port())
+	p := &proxy{ip: device.IP(), port: device.Port(), timeout: timeout}
+	for {
+		conn, err := listener.Accept()
+		checkErr(err)
+		go p.relay(conn)
+	}
+}
+
+type proxy struct {
+	ip      net.IP
+	port    int
+	timeout time.Duration
+
+	// serialises printing between relays
+	lock sync.Mutex
+}
+
+// relay opens a connection to the device for a sender, and copies frames
+// both ways until either end hangs up.
+func (p *proxy) relay(sender net.Conn) {
+	defer sender.Close()
+	name := sender.RemoteAddr().String()
+	fmt.Printf("Sender %s connected\n", name)
+
+	device := castnet.NewConnection()
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	err := device.Connect(ctx, p.ip, p.port)
+	cancel()
+	if err != nil {
+		fmt.Printf("Sender %s: %s\n", name, err)
+		return
+	}
+	defer device.Close()
+
+	// frames from the device are printed and written straight to the
+	// sender, instead of being dispatched to channels
+	var writeLock sync.Mutex
+	device.AddInterceptor(castnet.Inbound, func(envelope *castnet.Envelope) bool {
+		p.print(envelope.Direction, envelope.Message)
+		writeLock.Lock()
+		defer writeLock.Unlock()
+		if err := castnet.WriteMessage(sender, envelope.Message); err != nil {
+			sender.Close()
+		}
+		return false
+	})
+
+	go func() {
+		<-device.Done()
+		sender.Close()
+	}()
+
+	for {
+		message, err := castnet.ReadMessage(sender, castnet.DefaultMaxFrameSize)
+		if _, ok := err.(*castnet.InvalidMessageError); ok {
+			fmt.Printf("Sender %s: skipping frame: %s\n", name, err)
+			continue
+		}
+		if err != nil {
+			break
+		}
+		p.print(castnet.Outbound, message)
+		if err := device.SendMessage(message); err != nil {
+			fmt.Printf("Sender %s: %s\n", name, err)
+			break
+		}
+	}
+	fmt.Printf("Sender %s disconnected\n", name)
+}
+
+func (p *proxy) print(direction castnet.Direction, message *api.CastMessage) {
+	var payload string
+	if message.GetPayloadType() == api.CastMessage_BINARY {
+		payload = fmt.Sprintf("<%d bytes>", len(message.PayloadBinary))
+	} else {
+		var out bytes.Buffer
+		if err := json.Indent(&out, []byte(message.GetPayloadUtf8()), "", "  "); err != nil {
+			payload = message.GetPayloadUtf8()
+		} else {
+			payload = out.String()
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	fmt.Printf("%s %-8s %s → %s [%s]\n%s\n",
+		time.Now().Format("15:04:05.000"), direction, message.GetSourceId(), message.GetDestinationId(), message.GetNamespace(), payload)
+}
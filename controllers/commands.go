@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SupportedMediaCommands is the set of commands a media session accepts,
+// as reported in its MediaStatus.
+type SupportedMediaCommands int
+
+const (
+	CommandPause          SupportedMediaCommands = 1
+	CommandSeek           SupportedMediaCommands = 2
+	CommandStreamVolume   SupportedMediaCommands = 4
+	CommandStreamMute     SupportedMediaCommands = 8
+	CommandSkipForward    SupportedMediaCommands = 16
+	CommandSkipBackward   SupportedMediaCommands = 32
+	CommandQueueNext      SupportedMediaCommands = 64
+	CommandQueuePrev      SupportedMediaCommands = 128
+	CommandQueueShuffle   SupportedMediaCommands = 256
+	CommandQueueRepeatAll SupportedMediaCommands = 1024
+	CommandQueueRepeatOne SupportedMediaCommands = 2048
+	CommandQueueRepeat                           = CommandQueueRepeatAll | CommandQueueRepeatOne
+)
+
+var commandNames = []struct {
+	command SupportedMediaCommands
+	name    string
+}{
+	{CommandPause, "PAUSE"},
+	{CommandSeek, "SEEK"},
+	{CommandStreamVolume, "STREAM_VOLUME"},
+	{CommandStreamMute, "STREAM_MUTE"},
+	{CommandSkipForward, "SKIP_FORWARD"},
+	{CommandSkipBackward, "SKIP_BACKWARD"},
+	{CommandQueueNext, "QUEUE_NEXT"},
+	{CommandQueuePrev, "QUEUE_PREV"},
+	{CommandQueueShuffle, "QUEUE_SHUFFLE"},
+	{CommandQueueRepeatAll, "QUEUE_REPEAT_ALL"},
+	{CommandQueueRepeatOne, "QUEUE_REPEAT_ONE"},
+}
+
+// Has reports whether all of commands are supported.
+func (s SupportedMediaCommands) Has(commands SupportedMediaCommands) bool {
+	return s&commands == commands
+}
+
+func (s SupportedMediaCommands) String() string {
+	var names []string
+	for _, c := range commandNames {
+		if s.Has(c.command) {
+			names = append(names, c.name)
+			s &^= c.command
+		}
+	}
+	if s != 0 {
+		names = append(names, fmt.Sprintf("0x%x", int(s)))
+	}
+	if len(names) == 0 {
+		return "NONE"
+	}
+	return strings.Join(names, "|")
+}
+
+// UnsupportedCommandError is returned, without anything being sent, for a
+// command the current media session has said it does not support.
+type UnsupportedCommandError struct {
+	Command   SupportedMediaCommands
+	Supported SupportedMediaCommands
+}
+
+func (e *UnsupportedCommandError) Error() string {
+	return fmt.Sprintf("Command not supported by media session: %s (supports %s)", e.Command, e.Supported)
+}
+
+// checkSupported fails if the last status seen for session sessionID says
+// it does not support command. Until a status arrives, anything is allowed.
+func (c *MediaController) checkSupported(sessionID int, command SupportedMediaCommands) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	status := c.sessions[sessionID].status
+	if status != nil && !status.SupportedMediaCommands.Has(command) {
+		return &UnsupportedCommandError{Command: command, Supported: status.SupportedMediaCommands}
+	}
+	return nil
+}
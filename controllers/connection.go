@@ -10,8 +10,8 @@ type ConnectionController struct {
 	channel *net.Channel
 }
 
-var connect = net.PayloadHeaders{Type: "CONNECT"}
-var close = net.PayloadHeaders{Type: "CLOSE"}
+var commandConnect = net.PayloadHeaders{Type: "CONNECT"}
+var commandClose = net.PayloadHeaders{Type: "CLOSE"}
 
 func NewConnectionController(conn *net.Connection, eventsCh chan events.Event, sourceId, destinationId string) *ConnectionController {
 	controller := &ConnectionController{
@@ -22,9 +22,12 @@ func NewConnectionController(conn *net.Connection, eventsCh chan events.Event, s
 }
 
 func (c *ConnectionController) Start(ctx context.Context) error {
-	return c.channel.Send(connect)
+	return c.channel.Send(commandConnect)
 }
 
+// Close closes the virtual connection and releases its channel.
 func (c *ConnectionController) Close() error {
-	return c.channel.Send(close)
+	err := c.channel.Send(commandClose)
+	c.channel.Close()
+	return err
 }
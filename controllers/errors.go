@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/net"
+)
+
+// errorResponses are the reply types with which a device rejects a request.
+var errorResponses = map[string]bool{
+	"INVALID_REQUEST":      true,
+	"INVALID_PLAYER_STATE": true,
+	"LOAD_FAILED":          true,
+	"LOAD_CANCELLED":       true,
+	"LAUNCH_ERROR":         true,
+}
+
+// ResponseError is returned by controller requests that the device answered
+// with an error response, such as LOAD_FAILED or INVALID_REQUEST. Use
+// errors.As to inspect it.
+type ResponseError struct {
+	// Type is the type of the reply, e.g. "LOAD_FAILED".
+	Type string
+	// Reason is the reason given by the device, if any, e.g.
+	// "INVALID_MEDIA_SESSION_ID".
+	Reason            string
+	DetailedErrorCode int
+	// Payload is the reply as received.
+	Payload string
+}
+
+func (e *ResponseError) Error() string {
+	message := fmt.Sprintf("Request failed: %s", e.Type)
+	if e.Reason != "" {
+		message += ": " + e.Reason
+	}
+	if e.DetailedErrorCode != 0 {
+		message += fmt.Sprintf(" (error %d)", e.DetailedErrorCode)
+	}
+	return message
+}
+
+// checkResponse returns a *ResponseError if message is an error response.
+func checkResponse(message *api.CastMessage) error {
+	var response struct {
+		net.PayloadHeaders
+		Reason            string `json:"reason"`
+		DetailedErrorCode int    `json:"detailedErrorCode"`
+	}
+	if err := json.Unmarshal([]byte(message.GetPayloadUtf8()), &response); err != nil {
+		return fmt.Errorf("Failed to unmarshal response: %s - %s", err, message.GetPayloadUtf8())
+	}
+	if !errorResponses[response.Type] {
+		return nil
+	}
+	return &ResponseError{
+		Type:              response.Type,
+		Reason:            response.Reason,
+		DetailedErrorCode: response.DetailedErrorCode,
+		Payload:           message.GetPayloadUtf8(),
+	}
+}
+
+// sendRequest sends payload on channel and waits for the reply, failing if
+// the device rejects the request.
+func sendRequest(ctx context.Context, channel *net.Channel, payload net.Payload) (*api.CastMessage, error) {
+	message, err := channel.Request(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"errors"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,6 +20,9 @@ const maxBacklog = 3
 type HeartbeatController struct {
 	pongs    int64
 	ticker   *time.Ticker
+	stop     chan struct{}
+	lock     sync.Mutex
+	conn     *net.Connection
 	channel  *net.Channel
 	eventsCh chan events.Event
 }
@@ -28,6 +32,7 @@ var pong = net.PayloadHeaders{Type: "PONG"}
 
 func NewHeartbeatController(conn *net.Connection, eventsCh chan events.Event, sourceId, destinationId string) *HeartbeatController {
 	controller := &HeartbeatController{
+		conn:     conn,
 		channel:  conn.NewChannel(sourceId, destinationId, "urn:x-cast:com.google.cast.tp.heartbeat"),
 		eventsCh: eventsCh,
 	}
@@ -45,41 +50,42 @@ func (c *HeartbeatController) onPing(_ *api.CastMessage) {
 	}
 }
 
-func (c *HeartbeatController) sendEvent(event events.Event) {
-	select {
-	case c.eventsCh <- event:
-	default:
-		log.Printf("Dropped event: %#v", event)
-	}
-}
-
 func (c *HeartbeatController) onPong(_ *api.CastMessage) {
 	atomic.StoreInt64(&c.pongs, 0)
 }
 
 func (c *HeartbeatController) Start(ctx context.Context) error {
-	if c.ticker != nil {
-		c.Stop()
-	}
+	c.Stop()
+
+	c.lock.Lock()
+	ticker := time.NewTicker(interval)
+	stop := make(chan struct{})
+	c.ticker = ticker
+	c.stop = stop
+	c.lock.Unlock()
+	atomic.StoreInt64(&c.pongs, 0)
 
-	c.ticker = time.NewTicker(interval)
 	go func() {
+		defer ticker.Stop()
 	LOOP:
 		for {
 			select {
-			case <-c.ticker.C:
+			case <-ticker.C:
 				if atomic.LoadInt64(&c.pongs) >= maxBacklog {
-					log.Errorf("Missed %d pongs", c.pongs)
-					c.sendEvent(events.Disconnected{errors.New("Ping timeout")})
+					log.Errorf("Missed %d pongs", atomic.LoadInt64(&c.pongs))
+					// the connection reports the disconnect to its owner
+					c.conn.Abort(errors.New("Ping timeout"))
 					break LOOP
 				}
 				err := c.channel.Send(ping)
 				atomic.AddInt64(&c.pongs, 1)
 				if err != nil {
 					log.Errorf("Error sending ping: %s", err)
-					c.sendEvent(events.Disconnected{err})
+					c.conn.Abort(err)
 					break LOOP
 				}
+			case <-stop:
+				break LOOP
 			case <-ctx.Done():
 				log.Println("Heartbeat stopped")
 				break LOOP
@@ -92,8 +98,12 @@ func (c *HeartbeatController) Start(ctx context.Context) error {
 }
 
 func (c *HeartbeatController) Stop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	if c.ticker != nil {
 		c.ticker.Stop()
+		close(c.stop)
 		c.ticker = nil
+		c.stop = nil
 	}
 }
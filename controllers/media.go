@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -20,8 +23,29 @@ type MediaController struct {
 	eventsCh       chan events.Event
 	DestinationID  string
 	MediaSessionID int
+
+	// guards MediaSessionID, which is updated on the receive goroutine
+	lock sync.Mutex
+
+	// the latest status of each session not yet idle, and of the targeted
+	// session even once it is, by id
+	sessions map[int]seenStatus
+	// closed when the status next changes, if anyone is waiting
+	changed chan struct{}
+
+	now func() time.Time
+}
+
+// seenStatus is a status and when it arrived.
+type seenStatus struct {
+	status *MediaStatus
+	at     time.Time
 }
 
+// seekThreshold is how far the position may drift from where playback
+// should have reached before it is taken as a seek.
+const seekThreshold = 2.0
+
 const NamespaceMedia = "urn:x-cast:com.google.cast.media"
 
 var getMediaStatus = net.PayloadHeaders{Type: "GET_STATUS"}
@@ -30,31 +54,71 @@ var commandMediaPlay = net.PayloadHeaders{Type: "PLAY"}
 var commandMediaPause = net.PayloadHeaders{Type: "PAUSE"}
 var commandMediaStop = net.PayloadHeaders{Type: "STOP"}
 var commandMediaLoad = net.PayloadHeaders{Type: "LOAD"}
+var commandMediaSeek = net.PayloadHeaders{Type: "SEEK"}
+var commandMediaSetPlaybackRate = net.PayloadHeaders{Type: "SET_PLAYBACK_RATE"}
+var commandMediaSetVolume = net.PayloadHeaders{Type: "SET_VOLUME"}
+
+// Resume states for Seek. An empty resume state keeps the current player
+// state.
+const (
+	ResumeStatePlaybackStart = "PLAYBACK_START"
+	ResumeStatePlaybackPause = "PLAYBACK_PAUSE"
+)
+
+// Stream types of a MediaItem.
+const (
+	StreamTypeNone     = "NONE"
+	StreamTypeBuffered = "BUFFERED"
+	StreamTypeLive     = "LIVE"
+)
 
 type MediaCommand struct {
 	net.PayloadHeaders
 	MediaSessionID int `json:"mediaSessionId"`
 }
 
+type SeekCommand struct {
+	MediaCommand
+	CurrentTime float64 `json:"currentTime"`
+	ResumeState string  `json:"resumeState,omitempty"`
+}
+
+type PlaybackRateCommand struct {
+	MediaCommand
+	PlaybackRate float64 `json:"playbackRate"`
+}
+
+type MediaVolumeCommand struct {
+	MediaCommand
+	Volume Volume `json:"volume"`
+}
+
 type LoadMediaCommand struct {
 	net.PayloadHeaders
-	Media       MediaItem   `json:"media"`
-	CurrentTime int         `json:"currentTime"`
-	Autoplay    bool        `json:"autoplay"`
-	CustomData  interface{} `json:"customData"`
+	Media          MediaItem   `json:"media"`
+	CurrentTime    int         `json:"currentTime"`
+	Autoplay       bool        `json:"autoplay"`
+	CustomData     interface{} `json:"customData"`
+	ActiveTrackIDs []int       `json:"activeTrackIds,omitempty"`
 }
 
 type MediaItem struct {
-	ContentId   string `json:"contentId"`
-	StreamType  string `json:"streamType"`
-	ContentType string `json:"contentType"`
+	ContentId      string          `json:"contentId"`
+	StreamType     string          `json:"streamType"`
+	ContentType    string          `json:"contentType"`
+	Metadata       *MediaMetadata  `json:"metadata,omitempty"`
+	Tracks         []MediaTrack    `json:"tracks,omitempty"`
+	TextTrackStyle *TextTrackStyle `json:"textTrackStyle,omitempty"`
 }
 
 type MediaStatusMedia struct {
-	ContentId   string  `json:"contentId"`
-	StreamType  string  `json:"streamType"`
-	ContentType string  `json:"contentType"`
-	Duration    float64 `json:"duration"`
+	ContentId      string          `json:"contentId"`
+	StreamType     string          `json:"streamType"`
+	ContentType    string          `json:"contentType"`
+	Duration       float64         `json:"duration"`
+	Metadata       *MediaMetadata  `json:"metadata,omitempty"`
+	Tracks         []MediaTrack    `json:"tracks,omitempty"`
+	TextTrackStyle *TextTrackStyle `json:"textTrackStyle,omitempty"`
 }
 
 func NewMediaController(conn *net.Connection, eventsCh chan events.Event, sourceId, destinationID string) *MediaController {
@@ -62,6 +126,7 @@ func NewMediaController(conn *net.Connection, eventsCh chan events.Event, source
 		channel:       conn.NewChannel(sourceId, destinationID, NamespaceMedia),
 		eventsCh:      eventsCh,
 		DestinationID: destinationID,
+		now:           time.Now,
 	}
 
 	controller.channel.OnMessage("MEDIA_STATUS", controller.onStatus)
@@ -70,10 +135,15 @@ func NewMediaController(conn *net.Connection, eventsCh chan events.Event, source
 }
 
 func (c *MediaController) SetDestinationID(id string) {
-	c.channel.DestinationId = id
+	c.channel.SetDestinationId(id)
 	c.DestinationID = id
 }
 
+// Close releases the controller's channel once the app session has ended.
+func (c *MediaController) Close() {
+	c.channel.Close()
+}
+
 func (c *MediaController) sendEvent(event events.Event) {
 	select {
 	case c.eventsCh <- event:
@@ -86,6 +156,7 @@ func (c *MediaController) onStatus(message *api.CastMessage) {
 	response, err := c.parseStatus(message)
 	if err != nil {
 		log.Errorf("Error parsing status: %s", err)
+		return
 	}
 
 	for _, status := range response.Status {
@@ -93,6 +164,79 @@ func (c *MediaController) onStatus(message *api.CastMessage) {
 	}
 }
 
+// update records status, sending the events for what changed since the last
+// status of its session. Must be called with the lock held.
+func (c *MediaController) update(status *MediaStatus) {
+	if status.Media == nil {
+		// left out as unchanged since the last status of the session
+		status.Media = c.lastMedia(status.MediaSessionID)
+	}
+	for _, event := range c.statusEvents(status) {
+		c.sendEvent(event)
+	}
+	c.track(status)
+}
+
+// statusEvents compares status with the last one of its session, returning
+// the events for what changed. Must be called with the lock held, before
+// status is tracked.
+func (c *MediaController) statusEvents(status *MediaStatus) []events.Event {
+	now := c.now()
+	seen, sameSession := c.sessions[status.MediaSessionID]
+	previous, previousAt := seen.status, seen.at
+	if !sameSession && status.PlayerState == "IDLE" {
+		// a session first seen idle, or again once dropped, has nothing
+		// new to report
+		return nil
+	}
+
+	var evs []events.Event
+	if status.Media != nil {
+		newItem := !sameSession || previous.CurrentItemID != status.CurrentItemID ||
+			previous.Media != nil && previous.Media.ContentId != status.Media.ContentId
+		if newItem || previous.Media == nil {
+			evs = append(evs, events.MediaLoaded{
+				MediaSessionID: status.MediaSessionID,
+				ContentId:      status.Media.ContentId,
+				ContentType:    status.Media.ContentType,
+			})
+		}
+		if newItem {
+			// the previous item's state says nothing about the new one
+			sameSession = false
+		}
+	}
+
+	from := ""
+	if sameSession {
+		from = previous.PlayerState
+	}
+	if from != status.PlayerState {
+		evs = append(evs, events.PlayerStateChanged{
+			MediaSessionID: status.MediaSessionID,
+			From:           from,
+			To:             status.PlayerState,
+		})
+		if status.PlayerState == "IDLE" && status.IdleReason != "" {
+			evs = append(evs, events.MediaFinished{
+				MediaSessionID: status.MediaSessionID,
+				IdleReason:     status.IdleReason,
+			})
+		}
+	}
+
+	if sameSession && previous.PlayerState != "IDLE" && status.PlayerState != "IDLE" {
+		expected := previous.positionAfter(now.Sub(previousAt))
+		if math.Abs(status.CurrentTime-expected) > seekThreshold {
+			evs = append(evs, events.MediaSeeked{
+				MediaSessionID: status.MediaSessionID,
+				Position:       status.CurrentTime,
+			})
+		}
+	}
+	return evs
+}
+
 func (c *MediaController) parseStatus(message *api.CastMessage) (*MediaStatusResponse, error) {
 	response := &MediaStatusResponse{}
 
@@ -102,9 +246,18 @@ func (c *MediaController) parseStatus(message *api.CastMessage) (*MediaStatusRes
 		return nil, fmt.Errorf("Failed to unmarshal status message:%s - %s", err, *message.PayloadUtf8)
 	}
 
+	c.lock.Lock()
+	if response.Type == "MEDIA_STATUS" && len(response.Status) == 0 {
+		// every session has ended
+		c.sessions = nil
+	}
 	for _, status := range response.Status {
-		c.MediaSessionID = status.MediaSessionID
+		c.update(status)
+	}
+	if response.Type == "MEDIA_STATUS" {
+		notify(&c.changed)
 	}
+	c.lock.Unlock()
 
 	return response, nil
 }
@@ -120,12 +273,125 @@ type MediaStatus struct {
 	PlaybackRate           float64                `json:"playbackRate"`
 	PlayerState            string                 `json:"playerState"`
 	CurrentTime            float64                `json:"currentTime"`
-	SupportedMediaCommands int                    `json:"supportedMediaCommands"`
+	SupportedMediaCommands SupportedMediaCommands `json:"supportedMediaCommands"`
 	Volume                 *Volume                `json:"volume,omitempty"`
 	Media                  *MediaStatusMedia      `json:"media"`
 	CustomData             map[string]interface{} `json:"customData"`
 	RepeatMode             string                 `json:"repeatMode"`
 	IdleReason             string                 `json:"idleReason"`
+	Items                  []QueueItem            `json:"items,omitempty"`
+	CurrentItemID          int                    `json:"currentItemId,omitempty"`
+	ActiveTrackIDs         []int                  `json:"activeTrackIds,omitempty"`
+	LiveSeekableRange      *LiveSeekableRange     `json:"liveSeekableRange,omitempty"`
+	IsPlayingLiveEdge      bool                   `json:"isPlayingLiveEdge,omitempty"`
+}
+
+// LiveSeekableRange is the part of a live stream that can be seeked to, in
+// seconds on the same timeline as CurrentTime. End is the live edge.
+type LiveSeekableRange struct {
+	Start          float64 `json:"start"`
+	End            float64 `json:"end"`
+	IsMovingWindow bool    `json:"isMovingWindow,omitempty"`
+	IsLiveDone     bool    `json:"isLiveDone,omitempty"`
+}
+
+// IsLive reports whether the media is a live stream, which has no
+// meaningful duration.
+func (s *MediaStatus) IsLive() bool {
+	return s.Media != nil && s.Media.StreamType == StreamTypeLive
+}
+
+// lastMedia returns the media of the last status of session id, or nil if
+// it is not known. Must be called with the lock held.
+func (c *MediaController) lastMedia(id int) *MediaStatusMedia {
+	if seen, ok := c.sessions[id]; ok {
+		return seen.status.Media
+	}
+	return nil
+}
+
+// track records status, targeting commands at the session it is for unless
+// it has gone idle. Must be called with the lock held.
+func (c *MediaController) track(status *MediaStatus) {
+	id := status.MediaSessionID
+	if c.sessions == nil {
+		c.sessions = map[int]seenStatus{}
+	}
+	c.sessions[id] = seenStatus{status, c.now()}
+	if status.PlayerState != "IDLE" {
+		c.MediaSessionID = id
+	} else if c.MediaSessionID == id || !c.active(c.MediaSessionID) {
+		// the most recent of the sessions still active, or the idle one if
+		// there are none
+		c.MediaSessionID = id
+		for other := range c.sessions {
+			if c.active(other) && (c.MediaSessionID == id || other > c.MediaSessionID) {
+				c.MediaSessionID = other
+			}
+		}
+	}
+	// idle sessions are kept only while targeted
+	for other := range c.sessions {
+		if other != c.MediaSessionID && !c.active(other) {
+			delete(c.sessions, other)
+		}
+	}
+}
+
+// active reports whether session id has not gone idle. Must be called with
+// the lock held.
+func (c *MediaController) active(id int) bool {
+	seen, ok := c.sessions[id]
+	return ok && seen.status.PlayerState != "IDLE"
+}
+
+// Sessions returns the latest status of each media session that has not
+// gone idle, in the order they were started.
+func (c *MediaController) Sessions() []*MediaStatus {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	sessions := make([]*MediaStatus, 0, len(c.sessions))
+	for id, seen := range c.sessions {
+		if c.active(id) {
+			sessions = append(sessions, seen.status)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].MediaSessionID < sessions[j].MediaSessionID
+	})
+	return sessions
+}
+
+func (c *MediaController) sessionID() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.MediaSessionID
+}
+
+// EstimatedPosition returns where playback of the targeted session has got
+// to by now, extrapolated from its latest status, or false if there is no
+// media session.
+func (c *MediaController) EstimatedPosition() (float64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	seen, ok := c.sessions[c.MediaSessionID]
+	if !ok {
+		return 0, false
+	}
+	return seen.status.positionAfter(c.now().Sub(seen.at)), true
+}
+
+// positionAfter returns the playback position elapsed after the status was
+// reported.
+func (s *MediaStatus) positionAfter(elapsed time.Duration) float64 {
+	position := s.CurrentTime
+	if s.PlayerState == "PLAYING" {
+		position += elapsed.Seconds() * s.PlaybackRate
+	}
+	if s.Media != nil && s.Media.Duration > 0 && !s.IsLive() {
+		position = math.Min(position, s.Media.Duration)
+	}
+	return math.Max(position, 0)
 }
 
 func (c *MediaController) Start(ctx context.Context) error {
@@ -134,62 +400,189 @@ func (c *MediaController) Start(ctx context.Context) error {
 }
 
 func (c *MediaController) GetStatus(ctx context.Context) (*MediaStatusResponse, error) {
-	message, err := c.channel.Request(ctx, &getMediaStatus)
+	// copied, as the request id is set on it
+	request := getMediaStatus
+	message, err := sendRequest(ctx, c.channel, &request)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get receiver status: %s", err)
+		return nil, fmt.Errorf("Failed to get receiver status: %w", err)
 	}
 
 	return c.parseStatus(message)
 }
 
 func (c *MediaController) Play(ctx context.Context) (*api.CastMessage, error) {
-	message, err := c.channel.Request(ctx, &MediaCommand{commandMediaPlay, c.MediaSessionID})
+	return c.play(ctx, c.sessionID())
+}
+
+func (c *MediaController) play(ctx context.Context, sessionID int) (*api.CastMessage, error) {
+	message, err := sendRequest(ctx, c.channel, &MediaCommand{commandMediaPlay, sessionID})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to send play command: %s", err)
+		return nil, fmt.Errorf("Failed to send play command: %w", err)
 	}
+	c.parseStatus(message)
 	return message, nil
 }
 
 func (c *MediaController) Pause(ctx context.Context) (*api.CastMessage, error) {
-	message, err := c.channel.Request(ctx, &MediaCommand{commandMediaPause, c.MediaSessionID})
+	return c.pause(ctx, c.sessionID())
+}
+
+func (c *MediaController) pause(ctx context.Context, sessionID int) (*api.CastMessage, error) {
+	if err := c.checkSupported(sessionID, CommandPause); err != nil {
+		return nil, err
+	}
+	message, err := sendRequest(ctx, c.channel, &MediaCommand{commandMediaPause, sessionID})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to send pause command: %s", err)
+		return nil, fmt.Errorf("Failed to send pause command: %w", err)
 	}
+	c.parseStatus(message)
 	return message, nil
 }
 
 func (c *MediaController) Stop(ctx context.Context) (*api.CastMessage, error) {
-	if c.MediaSessionID == 0 {
+	sessionID := c.sessionID()
+	if sessionID == 0 {
 		// no current session to stop
 		return nil, nil
 	}
-	message, err := c.channel.Request(ctx, &MediaCommand{commandMediaStop, c.MediaSessionID})
+	return c.stop(ctx, sessionID)
+}
+
+func (c *MediaController) stop(ctx context.Context, sessionID int) (*api.CastMessage, error) {
+	message, err := sendRequest(ctx, c.channel, &MediaCommand{commandMediaStop, sessionID})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to send stop command: %s", err)
+		return nil, fmt.Errorf("Failed to send stop command: %w", err)
 	}
+	c.parseStatus(message)
 	return message, nil
 }
 
-func (c *MediaController) LoadMedia(ctx context.Context, media MediaItem, currentTime int, autoplay bool, customData interface{}) (*api.CastMessage, error) {
-	message, err := c.channel.Request(ctx, &LoadMediaCommand{
-		PayloadHeaders: commandMediaLoad,
-		Media:          media,
-		CurrentTime:    currentTime,
-		Autoplay:       autoplay,
-		CustomData:     customData,
+// Seek moves playback of the current media to position seconds, then
+// resumes in resumeState.
+func (c *MediaController) Seek(ctx context.Context, position float64, resumeState string) (*MediaStatus, error) {
+	return c.seek(ctx, c.sessionID(), position, resumeState)
+}
+
+func (c *MediaController) seek(ctx context.Context, sessionID int, position float64, resumeState string) (*MediaStatus, error) {
+	if err := c.checkSupported(sessionID, CommandSeek); err != nil {
+		return nil, err
+	}
+	message, err := sendRequest(ctx, c.channel, &SeekCommand{
+		MediaCommand: MediaCommand{commandMediaSeek, sessionID},
+		CurrentTime:  position,
+		ResumeState:  resumeState,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send seek command: %w", err)
+	}
+	return c.statusReply(message, sessionID)
+}
+
+// SeekFromLiveEdge moves playback of the current live stream to behind
+// seconds before the live edge, or to the start of the seekable range if
+// that is closer.
+func (c *MediaController) SeekFromLiveEdge(ctx context.Context, behind float64, resumeState string) (*MediaStatus, error) {
+	sessionID := c.sessionID()
+	response, err := c.GetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var status *MediaStatus
+	for _, s := range response.Status {
+		if s.MediaSessionID == sessionID {
+			status = s
+		}
+	}
+	if status == nil {
+		return nil, errors.New("No media session")
+	}
+	if !status.IsLive() || status.LiveSeekableRange == nil {
+		return nil, errors.New("Media is not a seekable live stream")
+	}
+	position := math.Max(status.LiveSeekableRange.Start, status.LiveSeekableRange.End-behind)
+	return c.seek(ctx, sessionID, position, resumeState)
+}
+
+// SetPlaybackRate changes the playback speed, 1 being normal speed.
+func (c *MediaController) SetPlaybackRate(ctx context.Context, rate float64) (*MediaStatus, error) {
+	return c.setPlaybackRate(ctx, c.sessionID(), rate)
+}
+
+func (c *MediaController) setPlaybackRate(ctx context.Context, sessionID int, rate float64) (*MediaStatus, error) {
+	message, err := sendRequest(ctx, c.channel, &PlaybackRateCommand{
+		MediaCommand: MediaCommand{commandMediaSetPlaybackRate, sessionID},
+		PlaybackRate: rate,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to send load command: %s", err)
+		return nil, fmt.Errorf("Failed to send playback rate command: %w", err)
 	}
+	return c.statusReply(message, sessionID)
+}
+
+// SetStreamVolume sets the volume of the current media stream, as opposed
+// to the device volume set through the ReceiverController.
+func (c *MediaController) SetStreamVolume(ctx context.Context, level float64) (*MediaStatus, error) {
+	return c.setStreamVolume(ctx, c.sessionID(), CommandStreamVolume, Volume{Level: &level})
+}
 
-	response := &net.PayloadHeaders{}
-	err = json.Unmarshal([]byte(*message.PayloadUtf8), response)
+// SetStreamMuted mutes or unmutes the current media stream.
+func (c *MediaController) SetStreamMuted(ctx context.Context, muted bool) (*MediaStatus, error) {
+	return c.setStreamVolume(ctx, c.sessionID(), CommandStreamMute, Volume{Muted: &muted})
+}
+
+// setStreamVolume sends volume to session sessionID, if it supports
+// command.
+func (c *MediaController) setStreamVolume(ctx context.Context, sessionID int, command SupportedMediaCommands, volume Volume) (*MediaStatus, error) {
+	if err := c.checkSupported(sessionID, command); err != nil {
+		return nil, err
+	}
+	message, err := sendRequest(ctx, c.channel, &MediaVolumeCommand{
+		MediaCommand: MediaCommand{commandMediaSetVolume, sessionID},
+		Volume:       volume,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send stream volume command: %w", err)
+	}
+	return c.statusReply(message, sessionID)
+}
+
+// statusReply returns the status of session sessionID a command was
+// answered with, or of the newest session if sessionID is zero, as after a
+// load.
+func (c *MediaController) statusReply(message *api.CastMessage, sessionID int) (*MediaStatus, error) {
+	response, err := c.parseStatus(message)
 	if err != nil {
 		return nil, err
 	}
-	if response.Type == "LOAD_FAILED" {
-		return nil, errors.New("Load media failed")
+	if response.Type != "MEDIA_STATUS" {
+		return nil, fmt.Errorf("Media command failed: %s", response.Type)
+	}
+	var status *MediaStatus
+	for _, s := range response.Status {
+		if sessionID == 0 && (status == nil || s.MediaSessionID > status.MediaSessionID) || s.MediaSessionID == sessionID {
+			status = s
+		}
 	}
+	if status == nil {
+		return nil, errors.New("No media session")
+	}
+	return status, nil
+}
 
+// LoadMedia loads media, with the tracks activeTrackIds of media.Tracks
+// enabled.
+func (c *MediaController) LoadMedia(ctx context.Context, media MediaItem, currentTime int, autoplay bool, customData interface{}, activeTrackIds ...int) (*api.CastMessage, error) {
+	message, err := sendRequest(ctx, c.channel, &LoadMediaCommand{
+		PayloadHeaders: commandMediaLoad,
+		Media:          media,
+		CurrentTime:    currentTime,
+		Autoplay:       autoplay,
+		CustomData:     customData,
+		ActiveTrackIDs: activeTrackIds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send load command: %w", err)
+	}
+	c.parseStatus(message)
 	return message, nil
 }
@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"errors"
+	gonet "net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/events"
+	"github.com/barnybug/go-cast/net"
+)
+
+func TestMediaStatusEvents(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := &MediaController{now: func() time.Time { return now }, eventsCh: make(chan events.Event, 10)}
+	update := func(status *MediaStatus) []events.Event {
+		c.lock.Lock()
+		c.update(status)
+		c.lock.Unlock()
+		var evs []events.Event
+		for len(c.eventsCh) > 0 {
+			evs = append(evs, <-c.eventsCh)
+		}
+		return evs
+	}
+	status := func(id int, contentId, state string, currentTime float64) *MediaStatus {
+		return &MediaStatus{
+			MediaSessionID: id,
+			PlayerState:    state,
+			PlaybackRate:   1,
+			CurrentTime:    currentTime,
+			Media:          &MediaStatusMedia{ContentId: contentId, ContentType: "video/mp4"},
+		}
+	}
+
+	assert.Equal(t, []events.Event{
+		events.MediaLoaded{MediaSessionID: 1, ContentId: "a", ContentType: "video/mp4"},
+		events.PlayerStateChanged{MediaSessionID: 1, From: "", To: "PLAYING"},
+	}, update(status(1, "a", "PLAYING", 0)))
+
+	// playing on as expected
+	now = now.Add(10 * time.Second)
+	assert.Empty(t, update(status(1, "a", "PLAYING", 10)))
+
+	now = now.Add(time.Second)
+	assert.Equal(t, []events.Event{
+		events.MediaSeeked{MediaSessionID: 1, Position: 60},
+	}, update(status(1, "a", "PLAYING", 60)))
+
+	now = now.Add(time.Second)
+	assert.Equal(t, []events.Event{
+		events.PlayerStateChanged{MediaSessionID: 1, From: "PLAYING", To: "PAUSED"},
+	}, update(status(1, "a", "PAUSED", 61)))
+
+	// paused, so the position should not have moved
+	now = now.Add(time.Minute)
+	assert.Empty(t, update(status(1, "a", "PAUSED", 61)))
+
+	finished := status(1, "a", "IDLE", 0)
+	finished.IdleReason = "FINISHED"
+	assert.Equal(t, []events.Event{
+		events.PlayerStateChanged{MediaSessionID: 1, From: "PAUSED", To: "IDLE"},
+		events.MediaFinished{MediaSessionID: 1, IdleReason: "FINISHED"},
+	}, update(finished))
+
+	assert.Equal(t, []events.Event{
+		events.MediaLoaded{MediaSessionID: 2, ContentId: "b", ContentType: "video/mp4"},
+		events.PlayerStateChanged{MediaSessionID: 2, From: "", To: "BUFFERING"},
+	}, update(status(2, "b", "BUFFERING", 0)))
+
+	// the media is left out when it has not changed
+	now = now.Add(time.Second)
+	partial := status(2, "", "PLAYING", 0)
+	partial.Media = nil
+	assert.Equal(t, []events.Event{
+		events.PlayerStateChanged{MediaSessionID: 2, From: "BUFFERING", To: "PLAYING"},
+	}, update(partial))
+	now = now.Add(time.Second)
+	assert.Empty(t, update(status(2, "b", "PLAYING", 1)))
+
+	// each session is compared with its own last status
+	assert.Equal(t, []events.Event{
+		events.MediaLoaded{MediaSessionID: 3, ContentId: "c", ContentType: "video/mp4"},
+		events.PlayerStateChanged{MediaSessionID: 3, From: "", To: "PLAYING"},
+	}, update(status(3, "c", "PLAYING", 0)))
+	now = now.Add(time.Second)
+	assert.Empty(t, update(status(2, "b", "PLAYING", 2)))
+	assert.Empty(t, update(status(3, "c", "PLAYING", 1)))
+
+	// a session is forgotten once it has gone idle and is not targeted
+	stopped := status(2, "b", "IDLE", 2)
+	stopped.IdleReason = "CANCELLED"
+	assert.Equal(t, []events.Event{
+		events.PlayerStateChanged{MediaSessionID: 2, From: "PLAYING", To: "IDLE"},
+		events.MediaFinished{MediaSessionID: 2, IdleReason: "CANCELLED"},
+	}, update(stopped))
+	assert.Empty(t, update(stopped))
+	c.lock.Lock()
+	assert.Len(t, c.sessions, 1)
+	c.lock.Unlock()
+}
+
+func TestSupportedMediaCommands(t *testing.T) {
+	assert.Equal(t, "NONE", SupportedMediaCommands(0).String())
+	assert.Equal(t, "PAUSE|SEEK|QUEUE_REPEAT_ALL|QUEUE_REPEAT_ONE|0x4000",
+		(CommandPause | CommandSeek | CommandQueueRepeat | 0x4000).String())
+	assert.True(t, (CommandPause | CommandQueueRepeat).Has(CommandQueueRepeatOne))
+	assert.False(t, CommandPause.Has(CommandPause|CommandSeek))
+}
+
+func TestEstimatedPosition(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := &MediaController{now: func() time.Time { return now }}
+	status := func(payload string) {
+		_, err := c.parseStatus(&api.CastMessage{PayloadUtf8: &payload})
+		require.NoError(t, err)
+	}
+
+	_, ok := c.EstimatedPosition()
+	assert.False(t, ok)
+
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":1,"playerState":"PLAYING","playbackRate":1.5,"currentTime":10,"media":{"duration":100}}]}`)
+	now = now.Add(20 * time.Second)
+	position, ok := c.EstimatedPosition()
+	assert.True(t, ok)
+	assert.Equal(t, 40.0, position)
+
+	// no further than the end
+	now = now.Add(time.Minute)
+	position, _ = c.EstimatedPosition()
+	assert.Equal(t, 100.0, position)
+
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":1,"playerState":"PAUSED","playbackRate":1,"currentTime":50,"media":{"duration":100}}]}`)
+	now = now.Add(time.Minute)
+	position, _ = c.EstimatedPosition()
+	assert.Equal(t, 50.0, position)
+
+	// a status without media keeps the duration of the last one
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":1,"playerState":"PLAYING","playbackRate":1,"currentTime":90}]}`)
+	now = now.Add(time.Minute)
+	position, _ = c.EstimatedPosition()
+	assert.Equal(t, 100.0, position)
+
+	// and each session has its own position
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":2,"playerState":"PAUSED","playbackRate":1,"currentTime":5,"media":{"duration":30}}]}`)
+	position, _ = c.Session(1).EstimatedPosition()
+	assert.Equal(t, 100.0, position)
+	position, _ = c.Session(2).EstimatedPosition()
+	assert.Equal(t, 5.0, position)
+
+	status(`{"type":"MEDIA_STATUS","status":[]}`)
+	_, ok = c.EstimatedPosition()
+	assert.False(t, ok)
+	_, ok = c.Session(1).EstimatedPosition()
+	assert.False(t, ok)
+}
+
+func TestMediaSessions(t *testing.T) {
+	c := &MediaController{now: time.Now}
+	status := func(payload string) {
+		_, err := c.parseStatus(&api.CastMessage{PayloadUtf8: &payload})
+		require.NoError(t, err)
+	}
+	ids := func() []int {
+		var ids []int
+		for _, status := range c.Sessions() {
+			ids = append(ids, status.MediaSessionID)
+		}
+		return ids
+	}
+
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":1,"playerState":"PLAYING"},{"mediaSessionId":2,"playerState":"PAUSED"}]}`)
+	assert.Equal(t, []int{1, 2}, ids())
+	assert.Equal(t, 2, c.sessionID())
+
+	// another sender's session going idle leaves the current one targeted
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":1,"playerState":"IDLE","idleReason":"INTERRUPTED"}]}`)
+	assert.Equal(t, []int{2}, ids())
+	assert.Equal(t, 2, c.sessionID())
+	assert.Nil(t, c.Session(1).Status())
+	assert.Equal(t, "PAUSED", c.Session(2).Status().PlayerState)
+
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":3,"playerState":"BUFFERING"}]}`)
+	assert.Equal(t, []int{2, 3}, ids())
+	assert.Equal(t, 3, c.sessionID())
+
+	// the current session finishing falls back on one still active
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":3,"playerState":"IDLE","idleReason":"FINISHED"}]}`)
+	assert.Equal(t, []int{2}, ids())
+	assert.Equal(t, 2, c.sessionID())
+
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":2,"playerState":"IDLE","idleReason":"CANCELLED"}]}`)
+	assert.Empty(t, ids())
+	assert.Equal(t, 2, c.sessionID())
+	idle, err := c.WaitForIdle(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "CANCELLED", idle)
+}
+
+func TestMediaSessionsTargeted(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := &MediaController{now: func() time.Time { return now }}
+	status := func(payload string) {
+		_, err := c.parseStatus(&api.CastMessage{PayloadUtf8: &payload})
+		require.NoError(t, err)
+	}
+
+	status(`{"type":"MEDIA_STATUS","status":[{"mediaSessionId":2,"playerState":"PAUSED","playbackRate":1,"currentTime":30},{"mediaSessionId":1,"playerState":"PLAYING","playbackRate":1,"currentTime":10}]}`)
+	require.Equal(t, 1, c.sessionID())
+
+	// a status of another session does not stand in for the targeted one
+	c.MediaSessionID = 2
+	now = now.Add(10 * time.Second)
+	position, ok := c.EstimatedPosition()
+	assert.True(t, ok)
+	assert.Equal(t, 30.0, position)
+	paused, err := c.WaitForState(context.Background(), "PAUSED")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, paused.MediaSessionID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = c.WaitForState(ctx, "PLAYING")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	position, _ = c.Session(1).EstimatedPosition()
+	assert.Equal(t, 20.0, position)
+}
+
+func TestSeekFromLiveEdgeTargetsSession(t *testing.T) {
+	// a live stream in session 2, listed before a buffered one in session 1
+	listener := statusServer(t, `{"type":"MEDIA_STATUS","requestId":%d,"status":[`+
+		`{"mediaSessionId":2,"playerState":"PLAYING","supportedMediaCommands":2,"media":{"streamType":"LIVE"},"liveSeekableRange":{"start":0,"end":100}},`+
+		`{"mediaSessionId":1,"playerState":"PLAYING","supportedMediaCommands":2,"media":{"streamType":"BUFFERED"}}]}`)
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	addr := listener.Addr().(*gonet.TCPAddr)
+	conn := net.NewConnection()
+	require.NoError(t, conn.Connect(ctx, addr.IP, addr.Port))
+	defer conn.Close()
+	c := NewMediaController(conn, nil, "sender-0", "web-1")
+
+	c.MediaSessionID = 1
+	_, err := c.SeekFromLiveEdge(ctx, 30, "")
+	assert.EqualError(t, err, "Media is not a seekable live stream")
+
+	c.MediaSessionID = 2
+	_, err = c.SeekFromLiveEdge(ctx, 30, "")
+	assert.NoError(t, err)
+
+	c.MediaSessionID = 3
+	_, err = c.SeekFromLiveEdge(ctx, 30, "")
+	assert.EqualError(t, err, "No media session")
+}
+
+func TestStatusReplyTargetsSession(t *testing.T) {
+	listener := statusServer(t, `{"type":"MEDIA_STATUS","requestId":%d,"status":[`+
+		`{"mediaSessionId":2,"playerState":"PLAYING","supportedMediaCommands":2},`+
+		`{"mediaSessionId":1,"playerState":"PAUSED","supportedMediaCommands":2}]}`)
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	addr := listener.Addr().(*gonet.TCPAddr)
+	conn := net.NewConnection()
+	require.NoError(t, conn.Connect(ctx, addr.IP, addr.Port))
+	defer conn.Close()
+	c := NewMediaController(conn, nil, "sender-0", "web-1")
+
+	c.MediaSessionID = 1
+	status, err := c.Seek(ctx, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.MediaSessionID)
+
+	// a load answers with the session it started
+	status, err = c.QueueLoad(ctx, []QueueItem{{Media: MediaItem{ContentId: "a"}}}, 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.MediaSessionID)
+}
+
+func TestMediaSessionCommands(t *testing.T) {
+	// session 1 takes queue commands but not stream volume, session 2 both
+	listener := statusServer(t, `{"type":"MEDIA_STATUS","requestId":%d,"status":[`+
+		`{"mediaSessionId":1,"playerState":"PLAYING","supportedMediaCommands":192},`+
+		`{"mediaSessionId":2,"playerState":"PLAYING","supportedMediaCommands":204}]}`)
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	addr := listener.Addr().(*gonet.TCPAddr)
+	conn := net.NewConnection()
+	require.NoError(t, conn.Connect(ctx, addr.IP, addr.Port))
+	defer conn.Close()
+	c := NewMediaController(conn, nil, "sender-0", "web-1")
+	_, err := c.GetStatus(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, c.sessionID())
+
+	status, err := c.SetStreamVolume(ctx, 0.5)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.MediaSessionID)
+
+	session := c.Session(1)
+	_, err = session.SetStreamVolume(ctx, 0.5)
+	var unsupported *UnsupportedCommandError
+	require.True(t, errors.As(err, &unsupported), "%v", err)
+	assert.Equal(t, CommandStreamVolume, unsupported.Command)
+
+	for _, command := range []func(context.Context) (*MediaStatus, error){
+		session.QueueNext,
+		session.QueuePrev,
+		func(ctx context.Context) (*MediaStatus, error) { return session.SetPlaybackRate(ctx, 2) },
+		func(ctx context.Context) (*MediaStatus, error) { return session.EditTracksInfo(ctx, nil, nil) },
+		func(ctx context.Context) (*MediaStatus, error) { return session.QueueRemove(ctx, 1) },
+	} {
+		status, err := command(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, status.MediaSessionID)
+	}
+}
@@ -0,0 +1,52 @@
+package controllers
+
+// Metadata types, which decide the fields of MediaMetadata a receiver
+// shows.
+const (
+	MetadataGeneric    = 0
+	MetadataMovie      = 1
+	MetadataTVShow     = 2
+	MetadataMusicTrack = 3
+	MetadataPhoto      = 4
+)
+
+type MediaImage struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// MediaMetadata describes media for display by the receiver. Which fields
+// apply depends on MetadataType. Dates are ISO 8601 strings.
+type MediaMetadata struct {
+	MetadataType int          `json:"metadataType"`
+	Title        string       `json:"title,omitempty"`
+	Subtitle     string       `json:"subtitle,omitempty"`
+	Images       []MediaImage `json:"images,omitempty"`
+	ReleaseDate  string       `json:"releaseDate,omitempty"`
+
+	// MetadataMovie
+	Studio string `json:"studio,omitempty"`
+
+	// MetadataTVShow
+	SeriesTitle     string `json:"seriesTitle,omitempty"`
+	Season          int    `json:"season,omitempty"`
+	Episode         int    `json:"episode,omitempty"`
+	OriginalAirdate string `json:"originalAirdate,omitempty"`
+
+	// MetadataMusicTrack
+	AlbumName   string `json:"albumName,omitempty"`
+	AlbumArtist string `json:"albumArtist,omitempty"`
+	Artist      string `json:"artist,omitempty"`
+	Composer    string `json:"composer,omitempty"`
+	TrackNumber int    `json:"trackNumber,omitempty"`
+	DiscNumber  int    `json:"discNumber,omitempty"`
+
+	// MetadataPhoto
+	Location         string  `json:"location,omitempty"`
+	Latitude         float64 `json:"latitude,omitempty"`
+	Longitude        float64 `json:"longitude,omitempty"`
+	Width            int     `json:"width,omitempty"`
+	Height           int     `json:"height,omitempty"`
+	CreationDateTime string  `json:"creationDateTime,omitempty"`
+}
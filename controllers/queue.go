@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/net"
+)
+
+var commandQueueLoad = net.PayloadHeaders{Type: "QUEUE_LOAD"}
+var commandQueueInsert = net.PayloadHeaders{Type: "QUEUE_INSERT"}
+var commandQueueRemove = net.PayloadHeaders{Type: "QUEUE_REMOVE"}
+var commandQueueReorder = net.PayloadHeaders{Type: "QUEUE_REORDER"}
+var commandQueueUpdate = net.PayloadHeaders{Type: "QUEUE_UPDATE"}
+
+// Repeat modes of a queue.
+const (
+	RepeatOff           = "REPEAT_OFF"
+	RepeatAll           = "REPEAT_ALL"
+	RepeatSingle        = "REPEAT_SINGLE"
+	RepeatAllAndShuffle = "REPEAT_ALL_AND_SHUFFLE"
+)
+
+// repeatCommands are the commands a session must support to take each
+// repeat mode.
+var repeatCommands = map[string]SupportedMediaCommands{
+	RepeatAll:           CommandQueueRepeatAll,
+	RepeatSingle:        CommandQueueRepeatOne,
+	RepeatAllAndShuffle: CommandQueueRepeatAll | CommandQueueShuffle,
+}
+
+// QueueItem is an entry in the media queue. ItemID is assigned by the
+// device, and must be left zero for new items.
+type QueueItem struct {
+	ItemID         int         `json:"itemId,omitempty"`
+	Media          MediaItem   `json:"media"`
+	Autoplay       bool        `json:"autoplay"`
+	StartTime      float64     `json:"startTime,omitempty"`
+	PreloadTime    float64     `json:"preloadTime,omitempty"`
+	ActiveTrackIDs []int       `json:"activeTrackIds,omitempty"`
+	CustomData     interface{} `json:"customData,omitempty"`
+}
+
+type QueueLoadCommand struct {
+	net.PayloadHeaders
+	Items      []QueueItem `json:"items"`
+	StartIndex int         `json:"startIndex"`
+	RepeatMode string      `json:"repeatMode,omitempty"`
+}
+
+type QueueInsertCommand struct {
+	MediaCommand
+	Items        []QueueItem `json:"items"`
+	InsertBefore int         `json:"insertBefore,omitempty"`
+}
+
+type QueueRemoveCommand struct {
+	MediaCommand
+	ItemIDs []int `json:"itemIds"`
+}
+
+type QueueReorderCommand struct {
+	MediaCommand
+	ItemIDs      []int `json:"itemIds"`
+	InsertBefore int   `json:"insertBefore,omitempty"`
+}
+
+type QueueUpdateCommand struct {
+	MediaCommand
+	Jump          int    `json:"jump,omitempty"`
+	CurrentItemID int    `json:"currentItemId,omitempty"`
+	RepeatMode    string `json:"repeatMode,omitempty"`
+}
+
+// QueueLoad replaces the queue with items, starting playback at startIndex.
+// The device moves through the queue by itself as each item ends.
+func (c *MediaController) QueueLoad(ctx context.Context, items []QueueItem, startIndex int, repeatMode string) (*MediaStatus, error) {
+	message, err := sendRequest(ctx, c.channel, &QueueLoadCommand{
+		PayloadHeaders: commandQueueLoad,
+		Items:          items,
+		StartIndex:     startIndex,
+		RepeatMode:     repeatMode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send queue load command: %w", err)
+	}
+	// the queue plays in a new session
+	return c.statusReply(message, 0)
+}
+
+// QueueInsert inserts items before the item with id insertBefore, or at the
+// end of the queue if insertBefore is zero.
+func (c *MediaController) QueueInsert(ctx context.Context, items []QueueItem, insertBefore int) (*MediaStatus, error) {
+	return c.queueInsert(ctx, c.sessionID(), items, insertBefore)
+}
+
+func (c *MediaController) queueInsert(ctx context.Context, sessionID int, items []QueueItem, insertBefore int) (*MediaStatus, error) {
+	return c.queueRequest(ctx, sessionID, &QueueInsertCommand{
+		MediaCommand: MediaCommand{commandQueueInsert, sessionID},
+		Items:        items,
+		InsertBefore: insertBefore,
+	})
+}
+
+// QueueRemove removes the items with itemIds from the queue.
+func (c *MediaController) QueueRemove(ctx context.Context, itemIds ...int) (*MediaStatus, error) {
+	return c.queueRemove(ctx, c.sessionID(), itemIds)
+}
+
+func (c *MediaController) queueRemove(ctx context.Context, sessionID int, itemIds []int) (*MediaStatus, error) {
+	return c.queueRequest(ctx, sessionID, &QueueRemoveCommand{
+		MediaCommand: MediaCommand{commandQueueRemove, sessionID},
+		ItemIDs:      itemIds,
+	})
+}
+
+// QueueReorder moves the items with itemIds, in that order, before the item
+// with id insertBefore, or to the end of the queue if insertBefore is zero.
+func (c *MediaController) QueueReorder(ctx context.Context, itemIds []int, insertBefore int) (*MediaStatus, error) {
+	return c.queueReorder(ctx, c.sessionID(), itemIds, insertBefore)
+}
+
+func (c *MediaController) queueReorder(ctx context.Context, sessionID int, itemIds []int, insertBefore int) (*MediaStatus, error) {
+	return c.queueRequest(ctx, sessionID, &QueueReorderCommand{
+		MediaCommand: MediaCommand{commandQueueReorder, sessionID},
+		ItemIDs:      itemIds,
+		InsertBefore: insertBefore,
+	})
+}
+
+// QueueNext skips to the next item in the queue.
+func (c *MediaController) QueueNext(ctx context.Context) (*MediaStatus, error) {
+	return c.queueUpdate(ctx, c.sessionID(), CommandQueueNext, QueueUpdateCommand{Jump: 1})
+}
+
+// QueuePrev goes back to the previous item in the queue.
+func (c *MediaController) QueuePrev(ctx context.Context) (*MediaStatus, error) {
+	return c.queueUpdate(ctx, c.sessionID(), CommandQueuePrev, QueueUpdateCommand{Jump: -1})
+}
+
+// QueueJump starts playing the item with itemId.
+func (c *MediaController) QueueJump(ctx context.Context, itemId int) (*MediaStatus, error) {
+	return c.queueUpdate(ctx, c.sessionID(), 0, QueueUpdateCommand{CurrentItemID: itemId})
+}
+
+// SetRepeatMode changes how the queue repeats once the last item ends.
+func (c *MediaController) SetRepeatMode(ctx context.Context, repeatMode string) (*MediaStatus, error) {
+	return c.queueUpdate(ctx, c.sessionID(), repeatCommands[repeatMode], QueueUpdateCommand{RepeatMode: repeatMode})
+}
+
+// queueUpdate sends update to session sessionID, if it supports command.
+func (c *MediaController) queueUpdate(ctx context.Context, sessionID int, command SupportedMediaCommands, update QueueUpdateCommand) (*MediaStatus, error) {
+	if err := c.checkSupported(sessionID, command); err != nil {
+		return nil, err
+	}
+	update.MediaCommand = MediaCommand{commandQueueUpdate, sessionID}
+	return c.queueRequest(ctx, sessionID, &update)
+}
+
+func (c *MediaController) queueRequest(ctx context.Context, sessionID int, command net.Payload) (*MediaStatus, error) {
+	message, err := sendRequest(ctx, c.channel, command)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send queue command: %w", err)
+	}
+	return c.statusReply(message, sessionID)
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -18,6 +19,11 @@ type ReceiverController struct {
 	channel  *net.Channel
 	eventsCh chan events.Event
 	status   *ReceiverStatus
+
+	appStopped []func(*ApplicationSession)
+	lock       sync.Mutex
+	// closed when the status next changes, if anyone is waiting
+	changed chan struct{}
 }
 
 var getStatus = net.PayloadHeaders{Type: "GET_STATUS"}
@@ -43,6 +49,15 @@ func (c *ReceiverController) sendEvent(event events.Event) {
 	}
 }
 
+// OnAppStopped registers cb to be called when a running app disappears from
+// the receiver status. It is called on the connection's receive goroutine,
+// so must not block on requests.
+func (c *ReceiverController) OnAppStopped(cb func(*ApplicationSession)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.appStopped = append(c.appStopped, cb)
+}
+
 func (c *ReceiverController) onStatus(message *api.CastMessage) {
 	response := &StatusResponse{}
 	err := json.Unmarshal([]byte(*message.PayloadUtf8), response)
@@ -51,21 +66,28 @@ func (c *ReceiverController) onStatus(message *api.CastMessage) {
 		return
 	}
 
+	c.lock.Lock()
+	status := c.status
+	c.status = response.Status
+	notify(&c.changed)
+	c.lock.Unlock()
+
+	// keyed by session, so an app relaunched in a new session counts as
+	// stopped and started again
 	previous := map[string]*ApplicationSession{}
-	if c.status != nil {
-		for _, app := range c.status.Applications {
-			previous[*app.AppID] = app
+	if status != nil {
+		for _, app := range status.Applications {
+			previous[app.sessionKey()] = app
 		}
 	}
 
-	c.status = response.Status
 	vol := response.Status.Volume
 	c.sendEvent(events.StatusUpdated{Level: *vol.Level, Muted: *vol.Muted})
 
 	for _, app := range response.Status.Applications {
-		if _, ok := previous[*app.AppID]; ok {
+		if _, ok := previous[app.sessionKey()]; ok {
 			// Already running
-			delete(previous, *app.AppID)
+			delete(previous, app.sessionKey())
 			continue
 		}
 		event := events.AppStarted{
@@ -77,6 +99,9 @@ func (c *ReceiverController) onStatus(message *api.CastMessage) {
 	}
 
 	// Stopped apps
+	c.lock.Lock()
+	callbacks := c.appStopped
+	c.lock.Unlock()
 	for _, app := range previous {
 		event := events.AppStopped{
 			AppID:       *app.AppID,
@@ -84,6 +109,9 @@ func (c *ReceiverController) onStatus(message *api.CastMessage) {
 			StatusText:  *app.StatusText,
 		}
 		c.sendEvent(event)
+		for _, cb := range callbacks {
+			cb(app)
+		}
 	}
 }
 
@@ -132,6 +160,15 @@ type ApplicationSession struct {
 	TransportId *string      `json:"transportId,omitempty"`
 }
 
+// sessionKey identifies the app session, falling back on the app id if the
+// device did not report a session id.
+func (a *ApplicationSession) sessionKey() string {
+	if a.SessionID != nil {
+		return *a.SessionID
+	}
+	return *a.AppID
+}
+
 type Namespace struct {
 	Name string `json:"name"`
 }
@@ -147,9 +184,11 @@ func (c *ReceiverController) Start(ctx context.Context) error {
 }
 
 func (c *ReceiverController) GetStatus(ctx context.Context) (*ReceiverStatus, error) {
-	message, err := c.channel.Request(ctx, &getStatus)
+	// copied, as the request id is set on it
+	request := getStatus
+	message, err := sendRequest(ctx, c.channel, &request)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get receiver status: %s", err)
+		return nil, fmt.Errorf("Failed to get receiver status: %w", err)
 	}
 
 	response := &StatusResponse{}
@@ -162,7 +201,7 @@ func (c *ReceiverController) GetStatus(ctx context.Context) (*ReceiverStatus, er
 }
 
 func (c *ReceiverController) SetVolume(ctx context.Context, volume *Volume) (*api.CastMessage, error) {
-	return c.channel.Request(ctx, &ReceiverStatus{
+	return sendRequest(ctx, c.channel, &ReceiverStatus{
 		PayloadHeaders: net.PayloadHeaders{Type: "SET_VOLUME"},
 		Volume:         volume,
 	})
@@ -177,12 +216,12 @@ func (c *ReceiverController) GetVolume(ctx context.Context) (*Volume, error) {
 }
 
 func (c *ReceiverController) LaunchApp(ctx context.Context, appId string) (*ReceiverStatus, error) {
-	message, err := c.channel.Request(ctx, &LaunchRequest{
+	message, err := sendRequest(ctx, c.channel, &LaunchRequest{
 		PayloadHeaders: commandLaunch,
 		AppId:          appId,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Failed sending request: %s", err)
+		return nil, fmt.Errorf("Failed to launch app: %w", err)
 	}
 
 	response := &StatusResponse{}
@@ -194,5 +233,6 @@ func (c *ReceiverController) LaunchApp(ctx context.Context, appId string) (*Rece
 }
 
 func (c *ReceiverController) QuitApp(ctx context.Context) (*api.CastMessage, error) {
-	return c.channel.Request(ctx, &commandStop)
+	request := commandStop
+	return sendRequest(ctx, c.channel, &request)
 }
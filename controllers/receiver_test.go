@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	gonet "net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/net"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiverStatus is an empty RECEIVER_STATUS, with the request id to fill in.
+const receiverStatus = `{"type":"RECEIVER_STATUS","requestId":%d,"status":{"applications":[],"volume":{"level":1,"muted":false}}}`
+
+// statusServer answers every request with payload, a format taking the
+// request id.
+func statusServer(t *testing.T, payload string) gonet.Listener {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	config := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var length uint32
+			if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+				return
+			}
+			packet := make([]byte, length)
+			if _, err := io.ReadFull(conn, packet); err != nil {
+				return
+			}
+			message := &api.CastMessage{}
+			if err := proto.Unmarshal(packet, message); err != nil {
+				return
+			}
+			var headers net.PayloadHeaders
+			if err := json.Unmarshal([]byte(message.GetPayloadUtf8()), &headers); err != nil || headers.RequestId == nil {
+				continue
+			}
+			body := fmt.Sprintf(payload, *headers.RequestId)
+			reply := &api.CastMessage{
+				ProtocolVersion: api.CastMessage_CASTV2_1_0.Enum(),
+				SourceId:        message.DestinationId,
+				DestinationId:   message.SourceId,
+				Namespace:       message.Namespace,
+				PayloadType:     api.CastMessage_STRING.Enum(),
+				PayloadUtf8:     &body,
+			}
+			data, _ := proto.Marshal(reply)
+			binary.Write(conn, binary.BigEndian, uint32(len(data)))
+			conn.Write(data)
+		}
+	}()
+	return listener
+}
+
+func TestConcurrentGetStatus(t *testing.T) {
+	listener := statusServer(t, receiverStatus)
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	addr := listener.Addr().(*gonet.TCPAddr)
+	conn := net.NewConnection()
+	require.NoError(t, conn.Connect(ctx, addr.IP, addr.Port))
+	defer conn.Close()
+	controller := NewReceiverController(conn, nil, "sender-0", "receiver-0")
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				_, err := controller.GetStatus(ctx)
+				assert.NoError(t, err)
+				_, err = controller.QuitApp(ctx)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
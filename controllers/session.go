@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+)
+
+// MediaSession sends commands to one media session, rather than to the one
+// the MediaController is currently targeting.
+type MediaSession struct {
+	ID         int
+	controller *MediaController
+}
+
+// Session returns a handle on the media session with id mediaSessionId, as
+// listed by Sessions.
+func (c *MediaController) Session(mediaSessionId int) *MediaSession {
+	return &MediaSession{ID: mediaSessionId, controller: c}
+}
+
+// Status returns the latest status of the session, or nil if it has gone
+// idle.
+func (s *MediaSession) Status() *MediaStatus {
+	c := s.controller
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.active(s.ID) {
+		return nil
+	}
+	return c.sessions[s.ID].status
+}
+
+// EstimatedPosition returns where playback of the session has got to by
+// now, extrapolated from its latest status, or false if it has gone idle.
+func (s *MediaSession) EstimatedPosition() (float64, bool) {
+	c := s.controller
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.active(s.ID) {
+		return 0, false
+	}
+	seen := c.sessions[s.ID]
+	return seen.status.positionAfter(c.now().Sub(seen.at)), true
+}
+
+func (s *MediaSession) Play(ctx context.Context) (*api.CastMessage, error) {
+	return s.controller.play(ctx, s.ID)
+}
+
+func (s *MediaSession) Pause(ctx context.Context) (*api.CastMessage, error) {
+	return s.controller.pause(ctx, s.ID)
+}
+
+func (s *MediaSession) Stop(ctx context.Context) (*api.CastMessage, error) {
+	return s.controller.stop(ctx, s.ID)
+}
+
+// Seek moves playback of the session to position seconds, then resumes in
+// resumeState.
+func (s *MediaSession) Seek(ctx context.Context, position float64, resumeState string) (*MediaStatus, error) {
+	return s.controller.seek(ctx, s.ID, position, resumeState)
+}
+
+// SetPlaybackRate changes the playback speed of the session, 1 being normal
+// speed.
+func (s *MediaSession) SetPlaybackRate(ctx context.Context, rate float64) (*MediaStatus, error) {
+	return s.controller.setPlaybackRate(ctx, s.ID, rate)
+}
+
+func (s *MediaSession) SetStreamVolume(ctx context.Context, level float64) (*MediaStatus, error) {
+	return s.controller.setStreamVolume(ctx, s.ID, CommandStreamVolume, Volume{Level: &level})
+}
+
+func (s *MediaSession) SetStreamMuted(ctx context.Context, muted bool) (*MediaStatus, error) {
+	return s.controller.setStreamVolume(ctx, s.ID, CommandStreamMute, Volume{Muted: &muted})
+}
+
+// EditTracksInfo switches the active tracks of the session's media, and
+// optionally restyles text tracks.
+func (s *MediaSession) EditTracksInfo(ctx context.Context, activeTrackIds []int, style *TextTrackStyle) (*MediaStatus, error) {
+	return s.controller.editTracksInfo(ctx, s.ID, activeTrackIds, style)
+}
+
+func (s *MediaSession) QueueInsert(ctx context.Context, items []QueueItem, insertBefore int) (*MediaStatus, error) {
+	return s.controller.queueInsert(ctx, s.ID, items, insertBefore)
+}
+
+func (s *MediaSession) QueueRemove(ctx context.Context, itemIds ...int) (*MediaStatus, error) {
+	return s.controller.queueRemove(ctx, s.ID, itemIds)
+}
+
+func (s *MediaSession) QueueReorder(ctx context.Context, itemIds []int, insertBefore int) (*MediaStatus, error) {
+	return s.controller.queueReorder(ctx, s.ID, itemIds, insertBefore)
+}
+
+func (s *MediaSession) QueueNext(ctx context.Context) (*MediaStatus, error) {
+	return s.controller.queueUpdate(ctx, s.ID, CommandQueueNext, QueueUpdateCommand{Jump: 1})
+}
+
+func (s *MediaSession) QueuePrev(ctx context.Context) (*MediaStatus, error) {
+	return s.controller.queueUpdate(ctx, s.ID, CommandQueuePrev, QueueUpdateCommand{Jump: -1})
+}
+
+func (s *MediaSession) QueueJump(ctx context.Context, itemId int) (*MediaStatus, error) {
+	return s.controller.queueUpdate(ctx, s.ID, 0, QueueUpdateCommand{CurrentItemID: itemId})
+}
+
+func (s *MediaSession) SetRepeatMode(ctx context.Context, repeatMode string) (*MediaStatus, error) {
+	return s.controller.queueUpdate(ctx, s.ID, repeatCommands[repeatMode], QueueUpdateCommand{RepeatMode: repeatMode})
+}
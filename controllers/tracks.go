@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/net"
+)
+
+var commandEditTracksInfo = net.PayloadHeaders{Type: "EDIT_TRACKS_INFO"}
+
+// Track types.
+const (
+	TrackText  = "TEXT"
+	TrackAudio = "AUDIO"
+	TrackVideo = "VIDEO"
+)
+
+// MediaTrack is a text, audio or video track of a MediaItem. Text tracks
+// may be loaded from a separate TrackContentId, such as a WebVTT file, and
+// have a Subtype of SUBTITLES, CAPTIONS, DESCRIPTIONS, CHAPTERS or METADATA.
+type MediaTrack struct {
+	TrackID          int         `json:"trackId"`
+	Type             string      `json:"type"`
+	TrackContentId   string      `json:"trackContentId,omitempty"`
+	TrackContentType string      `json:"trackContentType,omitempty"`
+	Subtype          string      `json:"subtype,omitempty"`
+	Name             string      `json:"name,omitempty"`
+	Language         string      `json:"language,omitempty"`
+	CustomData       interface{} `json:"customData,omitempty"`
+}
+
+// TextTrackStyle controls how text tracks are drawn. Colors are #RRGGBBAA
+// strings.
+type TextTrackStyle struct {
+	BackgroundColor           string      `json:"backgroundColor,omitempty"`
+	ForegroundColor           string      `json:"foregroundColor,omitempty"`
+	EdgeType                  string      `json:"edgeType,omitempty"`
+	EdgeColor                 string      `json:"edgeColor,omitempty"`
+	FontScale                 float64     `json:"fontScale,omitempty"`
+	FontFamily                string      `json:"fontFamily,omitempty"`
+	FontGenericFamily         string      `json:"fontGenericFamily,omitempty"`
+	FontStyle                 string      `json:"fontStyle,omitempty"`
+	WindowType                string      `json:"windowType,omitempty"`
+	WindowColor               string      `json:"windowColor,omitempty"`
+	WindowRoundedCornerRadius int         `json:"windowRoundedCornerRadius,omitempty"`
+	CustomData                interface{} `json:"customData,omitempty"`
+}
+
+type EditTracksInfoCommand struct {
+	MediaCommand
+	ActiveTrackIDs []int           `json:"activeTrackIds"`
+	TextTrackStyle *TextTrackStyle `json:"textTrackStyle,omitempty"`
+}
+
+// EditTracksInfo switches the active tracks of the current media, and
+// optionally restyles text tracks. An empty activeTrackIds disables all
+// tracks.
+func (c *MediaController) EditTracksInfo(ctx context.Context, activeTrackIds []int, style *TextTrackStyle) (*MediaStatus, error) {
+	return c.editTracksInfo(ctx, c.sessionID(), activeTrackIds, style)
+}
+
+func (c *MediaController) editTracksInfo(ctx context.Context, sessionID int, activeTrackIds []int, style *TextTrackStyle) (*MediaStatus, error) {
+	if activeTrackIds == nil {
+		activeTrackIds = []int{}
+	}
+	message, err := sendRequest(ctx, c.channel, &EditTracksInfoCommand{
+		MediaCommand:   MediaCommand{commandEditTracksInfo, sessionID},
+		ActiveTrackIDs: activeTrackIds,
+		TextTrackStyle: style,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send edit tracks command: %w", err)
+	}
+	return c.statusReply(message, sessionID)
+}
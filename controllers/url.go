@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"time"
 
@@ -54,10 +53,15 @@ func NewURLController(conn *net.Connection, eventsCh chan events.Event, sourceId
 }
 
 func (c *URLController) SetDestinationID(id string) {
-	c.channel.DestinationId = id
+	c.channel.SetDestinationId(id)
 	c.DestinationID = id
 }
 
+// Close releases the controller's channel once the app session has ended.
+func (c *URLController) Close() {
+	c.channel.Close()
+}
+
 func (c *URLController) sendEvent(event events.Event) {
 	select {
 	case c.eventsCh <- event:
@@ -118,32 +122,24 @@ func (c *URLController) Start(ctx context.Context) error {
 }
 
 func (c *URLController) GetStatus(ctx context.Context) (*URLStatusResponse, error) {
-	message, err := c.channel.Request(ctx, &getURLStatus)
+	// copied, as the request id is set on it
+	request := getURLStatus
+	message, err := sendRequest(ctx, c.channel, &request)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get receiver status: %s", err)
+		return nil, fmt.Errorf("Failed to get receiver status: %w", err)
 	}
 
 	return c.parseStatus(message)
 }
 
 func (c *URLController) LoadURL(ctx context.Context, url string) (*api.CastMessage, error) {
-	message, err := c.channel.Request(ctx, &LoadURLCommand{
+	message, err := sendRequest(ctx, c.channel, &LoadURLCommand{
 		PayloadHeaders: commandURLLoad,
 		URL:            url,
 		Type:           "loc",
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to send load command: %s", err)
+		return nil, fmt.Errorf("Failed to send load command: %w", err)
 	}
-
-	response := &net.PayloadHeaders{}
-	err = json.Unmarshal([]byte(*message.PayloadUtf8), response)
-	if err != nil {
-		return nil, err
-	}
-	if response.Type == "LOAD_FAILED" {
-		return nil, errors.New("Load URL failed")
-	}
-
 	return message, nil
 }
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"golang.org/x/net/context"
+)
+
+// notify wakes everyone waiting on *changed. Must be called with the lock
+// guarding changed held.
+func notify(changed *chan struct{}) {
+	if *changed != nil {
+		close(*changed)
+		*changed = nil
+	}
+}
+
+// waitChan returns a channel closed when *changed is next notified. Must be
+// called with the lock guarding changed held.
+func waitChan(changed *chan struct{}) chan struct{} {
+	if *changed == nil {
+		*changed = make(chan struct{})
+	}
+	return *changed
+}
+
+// WaitForState blocks until the targeted media session is in one of states,
+// as told by the status updates the device sends, returning that status.
+func (c *MediaController) WaitForState(ctx context.Context, states ...string) (*MediaStatus, error) {
+	for {
+		c.lock.Lock()
+		status, changed := c.sessions[c.MediaSessionID].status, waitChan(&c.changed)
+		c.lock.Unlock()
+
+		if status != nil {
+			for _, state := range states {
+				if status.PlayerState == state {
+					return status, nil
+				}
+			}
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// WaitForIdle blocks until playback stops, returning why, e.g. "FINISHED"
+// or "CANCELLED".
+func (c *MediaController) WaitForIdle(ctx context.Context) (string, error) {
+	status, err := c.WaitForState(ctx, "IDLE")
+	if err != nil {
+		return "", err
+	}
+	return status.IdleReason, nil
+}
+
+// WaitForApp blocks until the app appId is running, returning its session.
+func (c *ReceiverController) WaitForApp(ctx context.Context, appId string) (*ApplicationSession, error) {
+	c.lock.Lock()
+	changed := waitChan(&c.changed)
+	c.lock.Unlock()
+
+	// the app may already be running
+	status, err := c.GetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if app := status.GetSessionByAppId(appId); app != nil {
+			return app, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		c.lock.Lock()
+		status, changed = c.status, waitChan(&c.changed)
+		c.lock.Unlock()
+	}
+}
@@ -0,0 +1,8 @@
+package events
+
+// MediaFinished is emitted when media stops playing, with IdleReason
+// FINISHED, CANCELLED, INTERRUPTED or ERROR.
+type MediaFinished struct {
+	MediaSessionID int
+	IdleReason     string
+}
@@ -0,0 +1,9 @@
+package events
+
+// MediaLoaded is emitted when new media starts in a session, including when
+// a queue moves on to its next item.
+type MediaLoaded struct {
+	MediaSessionID int
+	ContentId      string
+	ContentType    string
+}
@@ -0,0 +1,8 @@
+package events
+
+// MediaSeeked is emitted when the playback position jumps, whichever sender
+// asked for it.
+type MediaSeeked struct {
+	MediaSessionID int
+	Position       float64
+}
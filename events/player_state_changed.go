@@ -0,0 +1,9 @@
+package events
+
+// PlayerStateChanged is emitted when the player state of a media session
+// changes, e.g. from BUFFERING to PLAYING. From is empty for a new session.
+type PlayerStateChanged struct {
+	MediaSessionID int
+	From           string
+	To             string
+}
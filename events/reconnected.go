@@ -0,0 +1,5 @@
+package events
+
+type Reconnected struct {
+	Attempts int
+}
@@ -0,0 +1,6 @@
+package events
+
+type Reconnecting struct {
+	Attempt int
+	Reason  error
+}
@@ -0,0 +1,350 @@
+// Package device implements the parts of a Cast device that the receiver
+// and casttest packages share: accepting senders, answering the connection,
+// heartbeat and receiver namespaces, and addressing replies and broadcasts.
+// What a device does with apps and media is left to a Handler.
+package device
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/barnybug/go-cast"
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/barnybug/go-cast/log"
+	castnet "github.com/barnybug/go-cast/net"
+)
+
+const (
+	namespaceConnection = "urn:x-cast:com.google.cast.tp.connection"
+	namespaceHeartbeat  = "urn:x-cast:com.google.cast.tp.heartbeat"
+	namespaceReceiver   = "urn:x-cast:com.google.cast.receiver"
+)
+
+// Handler is what differs between devices. Its methods are called with the
+// device locked, so may use its state but must not lock it.
+type Handler interface {
+	// Launch starts app, which may be adjusted before senders are told of
+	// it. Returning an error rejects the launch.
+	Launch(app *controllers.ApplicationSession) error
+	// Quit stops the running app.
+	Quit(app *controllers.ApplicationSession) error
+	// SetVolume changes the device volume. Only the fields that are set
+	// change.
+	SetVolume(volume controllers.Volume) error
+	// Media handles a message to the running app on the media namespace.
+	Media(p *Peer, message *api.CastMessage, headers *castnet.PayloadHeaders, payload []byte)
+}
+
+// Device is a Cast device. Its lock guards Volume and App, and whatever
+// state the Handler keeps.
+type Device struct {
+	sync.Mutex
+	Volume controllers.Volume
+	App    *controllers.ApplicationSession
+
+	handler  Handler
+	peers    map[*Peer]struct{}
+	sessions int
+	closed   bool
+}
+
+func New(handler Handler) *Device {
+	level, muted := 1.0, false
+	return &Device{
+		Volume:  controllers.Volume{Level: &level, Muted: &muted},
+		handler: handler,
+		peers:   make(map[*Peer]struct{}),
+	}
+}
+
+// RandomId returns a random id in the form of a UUID.
+func RandomId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Serve accepts senders on listener until it is closed.
+func (d *Device) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		d.Lock()
+		if d.closed {
+			d.Unlock()
+			conn.Close()
+			continue
+		}
+		p := newPeer(conn)
+		d.peers[p] = struct{}{}
+		d.Unlock()
+		go d.handle(p)
+	}
+}
+
+// Close disconnects every sender and refuses any more.
+func (d *Device) Close() {
+	d.Lock()
+	d.closed = true
+	d.Unlock()
+	d.Disconnect()
+}
+
+// Disconnect drops every connected sender, as if the network failed.
+func (d *Device) Disconnect() {
+	d.Lock()
+	defer d.Unlock()
+	for p := range d.peers {
+		p.close()
+	}
+}
+
+func (d *Device) handle(p *Peer) {
+	defer func() {
+		d.Lock()
+		delete(d.peers, p)
+		d.Unlock()
+		p.close()
+	}()
+
+	for {
+		message, err := castnet.ReadMessage(p.conn, castnet.DefaultMaxFrameSize)
+		if _, ok := err.(*castnet.InvalidMessageError); ok {
+			log.Errorf("Skipping frame: %s", err)
+			continue
+		}
+		if err != nil {
+			log.Printf("Sender disconnected: %s", err)
+			return
+		}
+		headers, err := castnet.DecodeHeaders(message)
+		if err != nil || headers == nil {
+			continue
+		}
+		d.dispatch(p, message, headers)
+	}
+}
+
+func (d *Device) dispatch(p *Peer, message *api.CastMessage, headers *castnet.PayloadHeaders) {
+	payload := []byte(message.GetPayloadUtf8())
+	switch message.GetNamespace() {
+	case namespaceConnection:
+		// virtual connections are implicit
+	case namespaceHeartbeat:
+		if headers.Type == "PING" {
+			p.Send(Reply(message, castnet.PayloadHeaders{Type: "PONG"}))
+		}
+	case namespaceReceiver:
+		d.receiver(p, message, headers, payload)
+	case controllers.NamespaceMedia:
+		d.Lock()
+		defer d.Unlock()
+		if d.App == nil || !hasNamespace(d.App, controllers.NamespaceMedia) || message.GetDestinationId() != *d.App.TransportId {
+			// nothing is listening on this transport
+			return
+		}
+		d.handler.Media(p, message, headers, payload)
+	default:
+		log.Printf("Ignoring message on %s", message.GetNamespace())
+	}
+}
+
+func hasNamespace(app *controllers.ApplicationSession, namespace string) bool {
+	for _, ns := range app.Namespaces {
+		if ns.Name == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Device) receiver(p *Peer, message *api.CastMessage, headers *castnet.PayloadHeaders, payload []byte) {
+	d.Lock()
+	defer d.Unlock()
+
+	switch headers.Type {
+	case "GET_STATUS":
+	case "LAUNCH":
+		var request controllers.LaunchRequest
+		json.Unmarshal(payload, &request)
+		if err := d.launch(request.AppId); err != nil {
+			log.Errorf("Failed to launch %s: %s", request.AppId, err)
+			p.Send(Reply(message, &ErrorResponse{
+				PayloadHeaders: castnet.PayloadHeaders{Type: "LAUNCH_ERROR", RequestId: headers.RequestId},
+				Reason:         "NOT_FOUND",
+			}))
+			return
+		}
+	case "STOP":
+		if d.App != nil {
+			if err := d.handler.Quit(d.App); err != nil {
+				p.Send(Reply(message, InvalidRequest(headers, "INVALID_COMMAND")))
+				return
+			}
+		}
+		d.App = nil
+	case "SET_VOLUME":
+		var request controllers.ReceiverStatus
+		json.Unmarshal(payload, &request)
+		if request.Volume == nil {
+			p.Send(Reply(message, InvalidRequest(headers, "INVALID_PARAMS")))
+			return
+		}
+		if err := d.handler.SetVolume(*request.Volume); err != nil {
+			p.Send(Reply(message, InvalidRequest(headers, "INVALID_PARAMS")))
+			return
+		}
+		if request.Volume.Level != nil {
+			level := *request.Volume.Level
+			d.Volume.Level = &level
+		}
+		if request.Volume.Muted != nil {
+			muted := *request.Volume.Muted
+			d.Volume.Muted = &muted
+		}
+	default:
+		p.Send(Reply(message, InvalidRequest(headers, "INVALID_COMMAND")))
+		return
+	}
+
+	d.Respond(p, message, headers, d.receiverStatus)
+}
+
+// Launch starts appId, as if another sender had, with namespaces as well as
+// any it has by default, and tells every sender. Must be called with the
+// lock held.
+func (d *Device) Launch(appId string, namespaces ...string) error {
+	if err := d.launch(appId); err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		if !hasNamespace(d.App, namespace) {
+			d.App.Namespaces = append(d.App.Namespaces, &controllers.Namespace{Name: namespace})
+		}
+	}
+	d.broadcast(namespaceReceiver, "receiver-0", d.receiverStatus(nil))
+	return nil
+}
+
+// launch starts appId, replacing any running app. Must be called with the
+// lock held.
+func (d *Device) launch(appId string) error {
+	sessionId := RandomId()
+	transportId := fmt.Sprintf("web-%d", d.sessions+1)
+	displayName := appId
+	statusText := ""
+	namespaces := []*controllers.Namespace{}
+	if appId == cast.AppMedia {
+		displayName = "Default Media Receiver"
+		statusText = "Ready To Cast"
+		namespaces = append(namespaces, &controllers.Namespace{Name: controllers.NamespaceMedia})
+	}
+	app := &controllers.ApplicationSession{
+		AppID:       &appId,
+		DisplayName: &displayName,
+		Namespaces:  namespaces,
+		SessionID:   &sessionId,
+		StatusText:  &statusText,
+		TransportId: &transportId,
+	}
+	if err := d.handler.Launch(app); err != nil {
+		return err
+	}
+	d.sessions++
+	d.App = app
+	return nil
+}
+
+// receiverStatus builds a RECEIVER_STATUS payload. Must be called with the
+// lock held.
+func (d *Device) receiverStatus(requestId *int) interface{} {
+	status := &controllers.ReceiverStatus{
+		Applications: []*controllers.ApplicationSession{},
+		Volume:       &d.Volume,
+	}
+	if d.App != nil {
+		status.Applications = append(status.Applications, d.App)
+	}
+	return &controllers.StatusResponse{
+		PayloadHeaders: castnet.PayloadHeaders{Type: "RECEIVER_STATUS", RequestId: requestId},
+		Status:         status,
+	}
+}
+
+// Respond replies to the requester with the status built by build, and
+// broadcasts it to every other sender. Must be called with the lock held.
+func (d *Device) Respond(p *Peer, message *api.CastMessage, headers *castnet.PayloadHeaders, build func(*int) interface{}) {
+	p.Send(Reply(message, build(headers.RequestId)))
+
+	zero := 0
+	broadcast := Reply(message, build(&zero))
+	wildcard := "*"
+	broadcast.DestinationId = &wildcard
+	for other := range d.peers {
+		if other != p {
+			other.Send(broadcast)
+		}
+	}
+}
+
+// BroadcastMedia sends payload to every sender from the running app on the
+// media namespace. Must be called with the lock held.
+func (d *Device) BroadcastMedia(payload interface{}) {
+	if d.App == nil {
+		return
+	}
+	d.broadcast(controllers.NamespaceMedia, *d.App.TransportId, payload)
+}
+
+func (d *Device) broadcast(namespace, source string, payload interface{}) {
+	wildcard := "*"
+	message := Reply(&api.CastMessage{
+		SourceId:      &wildcard,
+		DestinationId: &source,
+		Namespace:     &namespace,
+	}, payload)
+	for p := range d.peers {
+		p.Send(message)
+	}
+}
+
+// MediaStatus builds a MEDIA_STATUS payload of statuses.
+func MediaStatus(requestId *int, statuses ...*controllers.MediaStatus) *controllers.MediaStatusResponse {
+	return &controllers.MediaStatusResponse{
+		PayloadHeaders: castnet.PayloadHeaders{Type: "MEDIA_STATUS", RequestId: requestId},
+		Status:         append([]*controllers.MediaStatus{}, statuses...),
+	}
+}
+
+type ErrorResponse struct {
+	castnet.PayloadHeaders
+	Reason string `json:"reason"`
+}
+
+// InvalidRequest builds an INVALID_REQUEST reply to the request headers.
+func InvalidRequest(headers *castnet.PayloadHeaders, reason string) *ErrorResponse {
+	return &ErrorResponse{
+		PayloadHeaders: castnet.PayloadHeaders{Type: "INVALID_REQUEST", RequestId: headers.RequestId},
+		Reason:         reason,
+	}
+}
+
+// Reply addresses payload back to the sender of message.
+func Reply(message *api.CastMessage, payload interface{}) *api.CastMessage {
+	data, _ := json.Marshal(payload)
+	body := string(data)
+	return &api.CastMessage{
+		ProtocolVersion: api.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        message.DestinationId,
+		DestinationId:   message.SourceId,
+		Namespace:       message.Namespace,
+		PayloadType:     api.CastMessage_STRING.Enum(),
+		PayloadUtf8:     &body,
+	}
+}
@@ -0,0 +1,69 @@
+package device
+
+import (
+	"net"
+	"sync"
+
+	"github.com/barnybug/go-cast/api"
+	castnet "github.com/barnybug/go-cast/net"
+)
+
+// Peer is a sender connected to a device. Messages are written by one
+// goroutine per peer, so a sender sees them in the order they were sent.
+type Peer struct {
+	conn net.Conn
+
+	lock   sync.Mutex
+	queue  []*api.CastMessage
+	wake   chan struct{}
+	closed bool
+}
+
+func newPeer(conn net.Conn) *Peer {
+	p := &Peer{conn: conn, wake: make(chan struct{}, 1)}
+	go p.write()
+	return p
+}
+
+// Send queues message for the sender without blocking.
+func (p *Peer) Send(message *api.CastMessage) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return
+	}
+	p.queue = append(p.queue, message)
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Peer) write() {
+	for range p.wake {
+		p.lock.Lock()
+		queue := p.queue
+		p.queue = nil
+		p.lock.Unlock()
+
+		for _, message := range queue {
+			if err := castnet.WriteMessage(p.conn, message); err != nil {
+				p.close()
+				return
+			}
+		}
+	}
+}
+
+// close disconnects the sender, dropping anything not yet written.
+func (p *Peer) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	p.queue = nil
+	close(p.wake)
+	p.conn.Close()
+}
@@ -0,0 +1,38 @@
+package device
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/barnybug/go-cast/api"
+	castnet "github.com/barnybug/go-cast/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerSendsInOrder(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	p := newPeer(server)
+	defer p.close()
+
+	for i := 0; i < 100; i++ {
+		body := fmt.Sprintf(`{"type":"MEDIA_STATUS","requestId":%d}`, i)
+		p.Send(&api.CastMessage{
+			ProtocolVersion: api.CastMessage_CASTV2_1_0.Enum(),
+			SourceId:        &[]string{"receiver-0"}[0],
+			DestinationId:   &[]string{"*"}[0],
+			Namespace:       &[]string{"urn:x-cast:com.google.cast.media"}[0],
+			PayloadType:     api.CastMessage_STRING.Enum(),
+			PayloadUtf8:     &body,
+		})
+	}
+	for i := 0; i < 100; i++ {
+		message, err := castnet.ReadMessage(client, castnet.DefaultMaxFrameSize)
+		require.NoError(t, err)
+		headers, err := castnet.DecodeHeaders(message)
+		require.NoError(t, err)
+		assert.Equal(t, i, *headers.RequestId)
+	}
+}
@@ -0,0 +1,122 @@
+package net
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/gogo/protobuf/proto"
+)
+
+const NamespaceDeviceAuth = "urn:x-cast:com.google.cast.tp.deviceauth"
+
+// AuthError is returned by Authenticate when the device fails to prove it
+// is a genuine Cast device.
+type AuthError struct {
+	Reason string
+	Err    error
+}
+
+func (e *AuthError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Device authentication failed: %s: %s", e.Reason, e.Err)
+	}
+	return fmt.Sprintf("Device authentication failed: %s", e.Reason)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// Authenticate challenges the device to prove it is genuine. The device
+// must answer with a certificate that chains to roots, and a signature by
+// that certificate over the TLS certificate it presented on this
+// connection.
+func (c *Connection) Authenticate(ctx context.Context, roots *x509.CertPool) error {
+	current := c.current()
+	if current == nil {
+		return ErrConnectionClosed
+	}
+	conn, ok := current.(*tls.Conn)
+	if !ok {
+		return &AuthError{Reason: "connection is not TLS"}
+	}
+	peers := conn.ConnectionState().PeerCertificates
+	if len(peers) == 0 {
+		return &AuthError{Reason: "device presented no TLS certificate"}
+	}
+
+	challenge, err := proto.Marshal(&api.DeviceAuthMessage{Challenge: &api.AuthChallenge{}})
+	if err != nil {
+		return err
+	}
+
+	channel := c.NewChannel("sender-0", "receiver-0", NamespaceDeviceAuth)
+	defer channel.Close()
+	replies := make(chan *api.CastMessage, 1)
+	channel.OnBinaryMessage(func(message *api.CastMessage) {
+		select {
+		case replies <- message:
+		default:
+		}
+	})
+
+	if err := channel.SendBinary(challenge); err != nil {
+		return err
+	}
+
+	var reply *api.CastMessage
+	select {
+	case reply = <-replies:
+	case <-c.Done():
+		return ErrConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	message := &api.DeviceAuthMessage{}
+	if err := proto.Unmarshal(reply.PayloadBinary, message); err != nil {
+		return &AuthError{Reason: "malformed reply", Err: err}
+	}
+	if message.Error != nil {
+		return &AuthError{Reason: message.Error.GetErrorType().String()}
+	}
+	if message.Response == nil {
+		return &AuthError{Reason: "no response to challenge"}
+	}
+
+	return verifyAuthResponse(message.Response, peers[0], roots)
+}
+
+func verifyAuthResponse(response *api.AuthResponse, peer *x509.Certificate, roots *x509.CertPool) error {
+	cert, err := x509.ParseCertificate(response.ClientAuthCertificate)
+	if err != nil {
+		return &AuthError{Reason: "invalid device certificate", Err: err}
+	}
+
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return &AuthError{Reason: "untrusted device certificate", Err: err}
+	}
+
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return &AuthError{Reason: "device certificate does not have an RSA key"}
+	}
+	digest := sha1.Sum(peer.Raw)
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA1, digest[:], response.Signature)
+	if err != nil {
+		return &AuthError{Reason: "bad signature over TLS certificate", Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package net
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAuthority struct {
+	roots  *x509.CertPool
+	device []byte
+	key    *rsa.PrivateKey
+}
+
+// newTestAuthority creates a root CA and a device certificate issued by it.
+func newTestAuthority(t *testing.T) *testAuthority {
+	caKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Cast Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDer)
+	require.NoError(t, err)
+
+	deviceKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	deviceTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test Chromecast"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	deviceDer, err := x509.CreateCertificate(rand.Reader, deviceTemplate, ca, &deviceKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return &testAuthority{roots: roots, device: deviceDer, key: deviceKey}
+}
+
+func (a *testAuthority) respond(tlsCertificate []byte) *api.DeviceAuthMessage {
+	digest := sha1.Sum(tlsCertificate)
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA1, digest[:])
+	return &api.DeviceAuthMessage{
+		Response: &api.AuthResponse{
+			Signature:             signature,
+			ClientAuthCertificate: a.device,
+		},
+	}
+}
+
+func authenticate(t *testing.T, respond func([]byte) *api.DeviceAuthMessage, roots *x509.CertPool) error {
+	server := newAuthTestServer(t, respond)
+	defer server.Close()
+	conn := server.connect(t)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return conn.Authenticate(ctx, roots)
+}
+
+func TestAuthenticate(t *testing.T) {
+	authority := newTestAuthority(t)
+	assert.NoError(t, authenticate(t, authority.respond, authority.roots))
+}
+
+func TestAuthenticateUntrustedDevice(t *testing.T) {
+	authority := newTestAuthority(t)
+	other := newTestAuthority(t)
+
+	err := authenticate(t, authority.respond, other.roots)
+	authErr, ok := err.(*AuthError)
+	require.True(t, ok, "expected *AuthError, got %v", err)
+	assert.Equal(t, "untrusted device certificate", authErr.Reason)
+}
+
+func TestAuthenticateBadSignature(t *testing.T) {
+	authority := newTestAuthority(t)
+	respond := func(tlsCertificate []byte) *api.DeviceAuthMessage {
+		// signed over the wrong certificate
+		return authority.respond(authority.device)
+	}
+
+	err := authenticate(t, respond, authority.roots)
+	authErr, ok := err.(*AuthError)
+	require.True(t, ok, "expected *AuthError, got %v", err)
+	assert.Equal(t, "bad signature over TLS certificate", authErr.Reason)
+}
+
+func TestAuthenticateDeviceError(t *testing.T) {
+	authority := newTestAuthority(t)
+	respond := func([]byte) *api.DeviceAuthMessage {
+		return &api.DeviceAuthMessage{Error: &api.AuthError{ErrorType: api.AuthError_NO_TLS.Enum()}}
+	}
+
+	err := authenticate(t, respond, authority.roots)
+	authErr, ok := err.(*AuthError)
+	require.True(t, ok, "expected *AuthError, got %v", err)
+	assert.Equal(t, "NO_TLS", authErr.Reason)
+}
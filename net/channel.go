@@ -1,6 +1,8 @@
 package net
 
 import (
+	"errors"
+	"sync"
 	"sync/atomic"
 
 	"golang.org/x/net/context"
@@ -9,15 +11,23 @@ import (
 	"github.com/barnybug/go-cast/log"
 )
 
+// ErrChannelClosed is returned by requests and sends on a closed Channel.
+var ErrChannelClosed = errors.New("Channel closed")
+
 type Channel struct {
-	conn          *Connection
-	sourceId      string
+	conn     *Connection
+	sourceId string
+	// DestinationId is the peer the channel talks to. Use SetDestinationId
+	// to change it, so that the connection routes to the new peer.
 	DestinationId string
 	namespace     string
 	_             int32
 	requestId     int64
 	inFlight      map[int]chan *api.CastMessage
 	listeners     []channelListener
+	binary        []func(*api.CastMessage)
+	closed        bool
+	lock          sync.Mutex
 }
 
 type channelListener struct {
@@ -41,8 +51,21 @@ func NewChannel(conn *Connection, sourceId, destinationId, namespace string) *Ch
 	}
 }
 
+// Message dispatches an incoming message to the channel. headers is nil for
+// BINARY messages, which are only offered to OnBinaryMessage listeners.
 func (c *Channel) Message(message *api.CastMessage, headers *PayloadHeaders) {
-	if *message.DestinationId != "*" && (*message.SourceId != c.DestinationId || *message.DestinationId != c.sourceId || *message.Namespace != c.namespace) {
+	if *message.SourceId != c.destination() || *message.Namespace != c.namespace ||
+		(*message.DestinationId != "*" && *message.DestinationId != c.sourceId) {
+		return
+	}
+
+	if message.GetPayloadType() == api.CastMessage_BINARY {
+		c.lock.Lock()
+		listeners := c.binary
+		c.lock.Unlock()
+		for _, callback := range listeners {
+			callback(message)
+		}
 		return
 	}
 
@@ -51,14 +74,23 @@ func (c *Channel) Message(message *api.CastMessage, headers *PayloadHeaders) {
 		return
 	}
 
+	c.lock.Lock()
+	var response chan *api.CastMessage
 	if headers.RequestId != nil && *headers.RequestId != 0 {
 		if listener, ok := c.inFlight[*headers.RequestId]; ok {
-			listener <- message
+			response = listener
 			delete(c.inFlight, *headers.RequestId)
 		}
 	}
+	listeners := c.listeners
+	c.lock.Unlock()
+
+	if response != nil {
+		// buffered, so never blocks even if the requester has given up
+		response <- message
+	}
 
-	for _, listener := range c.listeners {
+	for _, listener := range listeners {
 		if listener.responseType == headers.Type {
 			listener.callback(message)
 		}
@@ -66,31 +98,111 @@ func (c *Channel) Message(message *api.CastMessage, headers *PayloadHeaders) {
 }
 
 func (c *Channel) OnMessage(responseType string, cb func(*api.CastMessage)) {
-	c.listeners = append(c.listeners, channelListener{responseType, cb})
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// copy on write so Message can iterate a snapshot without holding the lock
+	listeners := make([]channelListener, len(c.listeners), len(c.listeners)+1)
+	copy(listeners, c.listeners)
+	c.listeners = append(listeners, channelListener{responseType, cb})
+}
+
+// OnBinaryMessage registers cb to be called with every BINARY message
+// received on the channel.
+func (c *Channel) OnBinaryMessage(cb func(*api.CastMessage)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	listeners := make([]func(*api.CastMessage), len(c.binary), len(c.binary)+1)
+	copy(listeners, c.binary)
+	c.binary = append(listeners, cb)
+}
+
+func (c *Channel) destination() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.DestinationId
+}
+
+// SetDestinationId points the channel at a different peer.
+func (c *Channel) SetDestinationId(id string) {
+	c.conn.rerouteChannel(c, func() {
+		c.lock.Lock()
+		c.DestinationId = id
+		c.lock.Unlock()
+	})
+}
+
+func (c *Channel) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closed
+}
+
+// Close removes the channel from its connection, failing any requests
+// still waiting for a reply with ErrChannelClosed.
+func (c *Channel) Close() {
+	c.conn.removeChannel(c)
+	c.failRequests()
 }
 
 func (c *Channel) Send(payload interface{}) error {
-	return c.conn.Send(payload, c.sourceId, c.DestinationId, c.namespace)
+	if c.isClosed() {
+		return ErrChannelClosed
+	}
+	return c.conn.Send(payload, c.sourceId, c.destination(), c.namespace)
+}
+
+func (c *Channel) SendBinary(data []byte) error {
+	if c.isClosed() {
+		return ErrChannelClosed
+	}
+	return c.conn.SendBinary(data, c.sourceId, c.destination(), c.namespace)
 }
 
 func (c *Channel) Request(ctx context.Context, payload Payload) (*api.CastMessage, error) {
 	requestId := int(atomic.AddInt64(&c.requestId, 1))
 
 	payload.setRequestId(requestId)
-	response := make(chan *api.CastMessage)
+	response := make(chan *api.CastMessage, 1)
+	c.lock.Lock()
 	c.inFlight[requestId] = response
+	c.lock.Unlock()
 
 	err := c.Send(payload)
 	if err != nil {
-		delete(c.inFlight, requestId)
+		c.cancelRequest(requestId)
 		return nil, err
 	}
 
 	select {
-	case reply := <-response:
+	case reply, ok := <-response:
+		if !ok && c.isClosed() {
+			return nil, ErrChannelClosed
+		} else if !ok {
+			return nil, ErrConnectionClosed
+		}
 		return reply, nil
 	case <-ctx.Done():
-		delete(c.inFlight, requestId)
+		c.cancelRequest(requestId)
 		return nil, ctx.Err()
 	}
 }
+
+// failRequests abandons every in-flight request, causing each waiting
+// Request to return ErrConnectionClosed, or ErrChannelClosed if the channel
+// has been closed.
+func (c *Channel) failRequests() {
+	c.lock.Lock()
+	inFlight := c.inFlight
+	c.inFlight = make(map[int]chan *api.CastMessage)
+	c.lock.Unlock()
+
+	for _, response := range inFlight {
+		close(response)
+	}
+}
+
+func (c *Channel) cancelRequest(requestId int) {
+	c.lock.Lock()
+	delete(c.inFlight, requestId)
+	c.lock.Unlock()
+}
@@ -0,0 +1,87 @@
+package net
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelClose(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	conn := server.connect(t)
+	defer conn.Close()
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+
+	result := make(chan error)
+	go func() {
+		_, err := channel.Request(context.Background(), &PayloadHeaders{Type: "IGNORE"})
+		result <- err
+	}()
+	<-server.received
+
+	channel.Close()
+	select {
+	case err := <-result:
+		assert.Equal(t, ErrChannelClosed, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending request was not failed")
+	}
+
+	assert.Empty(t, conn.allChannels())
+	assert.Equal(t, ErrChannelClosed, channel.Send(PayloadHeaders{Type: "PING"}))
+	assert.NoError(t, conn.Err())
+}
+
+func TestChannelRouting(t *testing.T) {
+	client, device := net.Pipe()
+	conn := NewConnectionFromConn(client)
+	defer conn.Close()
+
+	received := make(chan string, 10)
+	listen := func(channel *Channel, name string) {
+		channel.OnMessage("STATUS", func(*api.CastMessage) {
+			received <- name
+		})
+	}
+	listen(conn.NewChannel("sender-0", "receiver-0", testNamespace), "receiver")
+	listen(conn.NewChannel("sender-0", "receiver-0", "urn:x-cast:com.example.other"), "other namespace")
+	moved := conn.NewChannel("sender-0", "transport-1", testNamespace)
+	listen(moved, "transport")
+	moved.SetDestinationId("transport-2")
+	closed := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+	listen(closed, "closed")
+	closed.Close()
+
+	send := func(source, destination string) {
+		message := testMessage(`{"type":"STATUS"}`)
+		message.SourceId = &source
+		message.DestinationId = &destination
+		require.NoError(t, WriteMessage(device, message))
+	}
+	next := func() string {
+		select {
+		case name := <-received:
+			return name
+		case <-time.After(5 * time.Second):
+			return "timeout"
+		}
+	}
+
+	send("receiver-0", "*")
+	assert.Equal(t, "receiver", next())
+	send("receiver-0", "sender-1")
+	send("transport-1", "sender-0")
+	send("transport-2", "sender-0")
+	assert.Equal(t, "transport", next())
+
+	assert.Len(t, conn.allChannels(), 3)
+	assert.Empty(t, received)
+}
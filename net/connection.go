@@ -2,100 +2,247 @@ package net
 
 import (
 	"crypto/tls"
-	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net"
+	"strconv"
+	"sync"
 
 	"golang.org/x/net/context"
 
 	"github.com/barnybug/go-cast/api"
 	"github.com/barnybug/go-cast/log"
-	"github.com/gogo/protobuf/proto"
 )
 
+// ErrConnectionClosed is returned by requests that were still waiting for a
+// reply when the connection went away, and by sends on a closed connection.
+var ErrConnectionClosed = errors.New("Connection closed")
+
 type Connection struct {
-	conn     *tls.Conn
-	channels []*Channel
+	// Dialer opens the transport for Connect. If nil, Connect dials TCP and
+	// runs a TLS handshake using TLSConfig.
+	Dialer Dialer
+	// TLSConfig is used by the default Dialer, or DefaultTLSConfig if nil.
+	TLSConfig *tls.Config
+	// MaxFrameSize limits the size of frames sent and received, defaulting
+	// to DefaultMaxFrameSize. A peer announcing a larger frame is
+	// disconnected.
+	MaxFrameSize uint32
+
+	conn         net.Conn
+	channels     map[channelKey][]*Channel
+	channelsLock sync.RWMutex
+	writeLock    sync.Mutex
+
+	lock   sync.Mutex
+	done   chan struct{}
+	reason error
+
+	interceptors     map[Direction][]Interceptor
+	interceptorsLock sync.RWMutex
+}
+
+// channelKey routes incoming messages to the channels talking to a peer on
+// a namespace.
+type channelKey struct {
+	namespace string
+	peer      string
+}
+
+func (c *Channel) key() channelKey {
+	return channelKey{namespace: c.namespace, peer: c.destination()}
 }
 
 func NewConnection() *Connection {
 	return &Connection{
 		conn:     nil,
-		channels: make([]*Channel, 0),
+		channels: make(map[channelKey][]*Channel),
 	}
 }
 
 func (c *Connection) NewChannel(sourceId, destinationId, namespace string) *Channel {
 	channel := NewChannel(c, sourceId, destinationId, namespace)
-	c.channels = append(c.channels, channel)
+	c.channelsLock.Lock()
+	c.addChannel(channel)
+	c.channelsLock.Unlock()
 	return channel
 }
 
+// addChannel and deleteChannel must be called with channelsLock held.
+func (c *Connection) addChannel(channel *Channel) {
+	key := channel.key()
+	c.channels[key] = append(c.channels[key], channel)
+}
+
+func (c *Connection) deleteChannel(channel *Channel) {
+	key := channel.key()
+	channels := c.channels[key]
+	for i, ch := range channels {
+		if ch == channel {
+			// copy, as the receive loop may be iterating the old slice
+			remaining := make([]*Channel, 0, len(channels)-1)
+			remaining = append(remaining, channels[:i]...)
+			remaining = append(remaining, channels[i+1:]...)
+			if len(remaining) == 0 {
+				delete(c.channels, key)
+			} else {
+				c.channels[key] = remaining
+			}
+			return
+		}
+	}
+}
+
+func (c *Connection) removeChannel(channel *Channel) {
+	c.channelsLock.Lock()
+	defer c.channelsLock.Unlock()
+	if channel.isClosed() {
+		return
+	}
+	c.deleteChannel(channel)
+	channel.lock.Lock()
+	channel.closed = true
+	channel.lock.Unlock()
+}
+
+// rerouteChannel re-registers channel under the key it has after update.
+func (c *Connection) rerouteChannel(channel *Channel, update func()) {
+	c.channelsLock.Lock()
+	defer c.channelsLock.Unlock()
+	if channel.isClosed() {
+		update()
+		return
+	}
+	c.deleteChannel(channel)
+	update()
+	c.addChannel(channel)
+}
+
+// route returns the channels a message from peer on namespace is for.
+func (c *Connection) route(namespace, peer string) []*Channel {
+	c.channelsLock.RLock()
+	defer c.channelsLock.RUnlock()
+	return c.channels[channelKey{namespace: namespace, peer: peer}]
+}
+
+func (c *Connection) allChannels() []*Channel {
+	c.channelsLock.RLock()
+	defer c.channelsLock.RUnlock()
+	var channels []*Channel
+	for _, ch := range c.channels {
+		channels = append(channels, ch...)
+	}
+	return channels
+}
+
+// NewConnectionFromConn runs the Cast protocol over an already established
+// transport, such as an in-memory pipe or a tunnel, and starts the receive
+// loop.
+func NewConnectionFromConn(conn net.Conn) *Connection {
+	c := NewConnection()
+	c.attach(conn)
+	return c
+}
+
+// Connect dials the device and starts the receive loop. It may be called
+// again after the connection has terminated to re-establish it; channels
+// created on the connection carry over.
 func (c *Connection) Connect(ctx context.Context, host net.IP, port int) error {
-	var err error
-	deadline, _ := ctx.Deadline()
-	dialer := &net.Dialer{
-		Deadline: deadline,
-	}
-	c.conn, err = tls.DialWithDialer(dialer, "tcp", fmt.Sprintf("%s:%d", host, port), &tls.Config{
-		InsecureSkipVerify: true,
-	})
+	dial := c.Dialer
+	if dial == nil {
+		dial = TLSDialer(nil, c.TLSConfig)
+	}
+	conn, err := dial(ctx, "tcp", net.JoinHostPort(host.String(), strconv.Itoa(port)))
 	if err != nil {
 		return fmt.Errorf("Failed to connect to Chromecast: %s", err)
 	}
 
+	c.attach(conn)
+	return nil
+}
+
+// attach takes over conn as the transport and starts receiving from it.
+func (c *Connection) attach(conn net.Conn) {
+	// drop any previous socket before taking over
+	c.Close()
+
+	c.lock.Lock()
+	c.conn = conn
+	c.done = make(chan struct{})
+	c.reason = nil
+	c.lock.Unlock()
+
 	go c.ReceiveLoop()
+}
 
-	return nil
+// Done returns a channel that is closed once the connection has terminated,
+// either because the receive loop failed or Close/Abort was called.
+func (c *Connection) Done() <-chan struct{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.done
+}
+
+// Err returns the reason the connection terminated, or nil while it is
+// still open.
+func (c *Connection) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.reason
 }
 
 func (c *Connection) ReceiveLoop() {
+	c.lock.Lock()
+	conn := c.conn
+	c.lock.Unlock()
+
+	err := c.receive(conn)
+	log.Printf("Receive loop terminated: %s", err)
+	c.shutdown(conn, err)
+}
+
+func (c *Connection) maxFrameSize() uint32 {
+	if c.MaxFrameSize == 0 {
+		return DefaultMaxFrameSize
+	}
+	return c.MaxFrameSize
+}
+
+func (c *Connection) receive(conn net.Conn) error {
 	for {
-		var length uint32
-		err := binary.Read(c.conn, binary.BigEndian, &length)
-		if err != nil {
-			log.Printf("Failed to read packet length: %s", err)
-			break
-		}
-		if length == 0 {
-			log.Println("Empty packet received")
+		message, err := ReadMessage(conn, c.maxFrameSize())
+		if _, ok := err.(*InvalidMessageError); ok {
+			log.Errorf("Skipping frame: %s", err)
 			continue
 		}
-
-		packet := make([]byte, length)
-		i, err := io.ReadFull(c.conn, packet)
 		if err != nil {
-			log.Printf("Failed to read packet: %s", err)
-			break
+			return err
 		}
 
-		if i != int(length) {
-			log.Printf("Invalid packet size. Wanted: %d Read: %d", length, i)
-			break
+		// interceptors see every frame, even one whose headers do not parse
+		headers, ok := c.intercept(Inbound, message, nil)
+		if !ok {
+			continue
 		}
-
-		message := &api.CastMessage{}
-		err = proto.Unmarshal(packet, message)
-		if err != nil {
-			log.Printf("Failed to unmarshal CastMessage: %s", err)
-			break
+		if headers == nil && message.GetPayloadType() != api.CastMessage_BINARY {
+			if headers, err = DecodeHeaders(message); err != nil {
+				log.Errorf("Skipping frame: %s", err)
+				continue
+			}
 		}
 
-		log.Printf("%s ⇐ %s [%s]: %+v",
-			*message.DestinationId, *message.SourceId, *message.Namespace, *message.PayloadUtf8)
-
-		var headers PayloadHeaders
-		err = json.Unmarshal([]byte(*message.PayloadUtf8), &headers)
-
-		if err != nil {
-			log.Printf("Failed to unmarshal message: %s", err)
-			break
+		// binary payloads are opaque, so carry no headers
+		if headers == nil {
+			log.Printf("%s ⇐ %s [%s]: <%d bytes>",
+				*message.DestinationId, *message.SourceId, *message.Namespace, len(message.PayloadBinary))
+		} else {
+			log.Printf("%s ⇐ %s [%s]: %+v",
+				*message.DestinationId, *message.SourceId, *message.Namespace, *message.PayloadUtf8)
 		}
 
-		for _, channel := range c.channels {
-			channel.Message(message, &headers)
+		for _, channel := range c.route(*message.Namespace, *message.SourceId) {
+			channel.Message(message, headers)
 		}
 	}
 }
@@ -115,24 +262,99 @@ func (c *Connection) Send(payload interface{}, sourceId, destinationId, namespac
 		PayloadUtf8:     &payloadString,
 	}
 
-	proto.SetDefaults(message)
+	log.Printf("%s ⇒ %s [%s]: %s", *message.SourceId, *message.DestinationId, *message.Namespace, *message.PayloadUtf8)
 
-	data, err := proto.Marshal(message)
-	if err != nil {
-		return err
+	return c.send(message)
+}
+
+// SendBinary sends data as is in a BINARY frame.
+func (c *Connection) SendBinary(data []byte, sourceId, destinationId, namespace string) error {
+	message := &api.CastMessage{
+		ProtocolVersion: api.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        &sourceId,
+		DestinationId:   &destinationId,
+		Namespace:       &namespace,
+		PayloadType:     api.CastMessage_BINARY.Enum(),
+		PayloadBinary:   data,
 	}
 
-	log.Printf("%s ⇒ %s [%s]: %s", *message.SourceId, *message.DestinationId, *message.Namespace, *message.PayloadUtf8)
+	log.Printf("%s ⇒ %s [%s]: <%d bytes>", *message.SourceId, *message.DestinationId, *message.Namespace, len(data))
+
+	return c.send(message)
+}
+
+// SendMessage sends message as is, for relaying frames built elsewhere.
+func (c *Connection) SendMessage(message *api.CastMessage) error {
+	return c.send(message)
+}
 
-	err = binary.Write(c.conn, binary.BigEndian, uint32(len(data)))
+func (c *Connection) send(message *api.CastMessage) error {
+	c.lock.Lock()
+	conn, open := c.conn, c.reason == nil
+	c.lock.Unlock()
+	if conn == nil || !open {
+		return ErrConnectionClosed
+	}
+
+	if _, ok := c.intercept(Outbound, message, nil); !ok {
+		return nil
+	}
+
+	frame, err := EncodeFrame(message)
 	if err != nil {
 		return err
 	}
-	_, err = c.conn.Write(data)
+	if length := uint32(len(frame) - 4); length > c.maxFrameSize() {
+		return &FrameTooLargeError{Length: length, Max: c.maxFrameSize()}
+	}
+
+	// write the length prefix and body in a single call so that concurrent
+	// senders can never interleave their frames
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, err = conn.Write(frame)
 	return err
 }
 
+// shutdown closes the socket, records why and fails every pending request.
+// Only the first reason is kept, and a socket that has since been replaced
+// by a reconnect is simply closed.
+func (c *Connection) shutdown(conn net.Conn, reason error) error {
+	if reason == nil {
+		reason = ErrConnectionClosed
+	}
+	c.lock.Lock()
+	if conn != c.conn {
+		c.lock.Unlock()
+		return conn.Close()
+	}
+	if c.done == nil || c.reason != nil {
+		c.lock.Unlock()
+		return nil
+	}
+	c.reason = reason
+	err := c.conn.Close()
+	close(c.done)
+	c.lock.Unlock()
+
+	for _, channel := range c.allChannels() {
+		channel.failRequests()
+	}
+	return err
+}
+
+func (c *Connection) current() net.Conn {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.conn
+}
+
+// Abort terminates the connection, reporting reason from Err.
+func (c *Connection) Abort(reason error) {
+	c.shutdown(c.current(), reason)
+}
+
 func (c *Connection) Close() error {
 	// TODO: graceful shutdown
-	return c.conn.Close()
+	return c.shutdown(c.current(), ErrConnectionClosed)
 }
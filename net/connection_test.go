@@ -0,0 +1,390 @@
+package net
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNamespace = "urn:x-cast:com.example.test"
+
+// testServer is a minimal device that echoes every request back to the
+// sender as a PONG with the same request id.
+type testServer struct {
+	listener    net.Listener
+	certificate tls.Certificate
+	received    chan *api.CastMessage
+	accepted    chan net.Conn
+
+	// authenticate answers device auth challenges, given the server's TLS
+	// certificate
+	authenticate func(tlsCertificate []byte) *api.DeviceAuthMessage
+}
+
+func selfSignedCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	return newAuthTestServer(t, nil)
+}
+
+func newAuthTestServer(t *testing.T, authenticate func([]byte) *api.DeviceAuthMessage) *testServer {
+	certificate := selfSignedCertificate(t)
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+
+	s := &testServer{
+		listener:     listener,
+		certificate:  certificate,
+		received:     make(chan *api.CastMessage, 1000),
+		accepted:     make(chan net.Conn, 10),
+		authenticate: authenticate,
+	}
+	go s.serve()
+	return s
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.accepted <- conn
+		go s.handle(conn)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		var length uint32
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		packet := make([]byte, length)
+		if _, err := io.ReadFull(conn, packet); err != nil {
+			return
+		}
+		message := &api.CastMessage{}
+		if err := proto.Unmarshal(packet, message); err != nil {
+			return
+		}
+		s.received <- message
+
+		if message.GetPayloadType() == api.CastMessage_BINARY {
+			message.SourceId, message.DestinationId = message.DestinationId, message.SourceId
+			if message.GetNamespace() == NamespaceDeviceAuth && s.authenticate != nil {
+				reply := s.authenticate(s.certificate.Certificate[0])
+				message.PayloadBinary, _ = proto.Marshal(reply)
+			}
+			// otherwise echo binary frames straight back
+			data, _ := proto.Marshal(message)
+			binary.Write(conn, binary.BigEndian, uint32(len(data)))
+			conn.Write(data)
+			continue
+		}
+
+		var headers PayloadHeaders
+		if err := json.Unmarshal([]byte(message.GetPayloadUtf8()), &headers); err != nil || headers.RequestId == nil {
+			continue
+		}
+		if headers.Type == "IGNORE" {
+			continue
+		}
+		payload := fmt.Sprintf(`{"type":"PONG","requestId":%d}`, *headers.RequestId)
+		reply := &api.CastMessage{
+			ProtocolVersion: api.CastMessage_CASTV2_1_0.Enum(),
+			SourceId:        message.DestinationId,
+			DestinationId:   message.SourceId,
+			Namespace:       message.Namespace,
+			PayloadType:     api.CastMessage_STRING.Enum(),
+			PayloadUtf8:     &payload,
+		}
+		data, _ := proto.Marshal(reply)
+		binary.Write(conn, binary.BigEndian, uint32(len(data)))
+		conn.Write(data)
+	}
+}
+
+func (s *testServer) connect(t *testing.T) *Connection {
+	conn := NewConnection()
+	s.reconnect(t, conn)
+	return conn
+}
+
+func (s *testServer) reconnect(t *testing.T, conn *Connection) {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, conn.Connect(ctx, addr.IP, addr.Port))
+}
+
+func (s *testServer) Close() {
+	s.listener.Close()
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	conn := server.connect(t)
+	defer conn.Close()
+
+	const workers = 8
+	const requests = 50
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		channel := conn.NewChannel("sender-0", fmt.Sprintf("receiver-%d", w), testNamespace)
+		wg.Add(1)
+		go func(channel *Channel) {
+			defer wg.Done()
+			for i := 0; i < requests; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				reply, err := channel.Request(ctx, &PayloadHeaders{Type: "PING"})
+				cancel()
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, channel.DestinationId, reply.GetSourceId())
+			}
+		}(channel)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentSendAndListen(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	conn := server.connect(t)
+	defer conn.Close()
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, channel.Send(PayloadHeaders{Type: "NOTIFY"}))
+		}()
+		go func() {
+			defer wg.Done()
+			channel.OnMessage("PONG", func(*api.CastMessage) {})
+			conn.NewChannel("sender-0", "receiver-1", testNamespace)
+		}()
+	}
+	wg.Wait()
+
+	for w := 0; w < workers; w++ {
+		select {
+		case message := <-server.received:
+			assert.Equal(t, `{"type":"NOTIFY"}`, message.GetPayloadUtf8())
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for frames")
+		}
+	}
+}
+
+func TestRequestTimeoutDoesNotBlockReceiver(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	conn := server.connect(t)
+	defer conn.Close()
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+
+	// abandon a request before the reply arrives
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	channel.Request(ctx, &PayloadHeaders{Type: "PING"})
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := channel.Request(ctx, &PayloadHeaders{Type: "PING"})
+	assert.NoError(t, err)
+}
+
+func TestPeerDisconnectFailsPendingRequests(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	conn := server.connect(t)
+	defer conn.Close()
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+
+	result := make(chan error)
+	go func() {
+		_, err := channel.Request(context.Background(), &PayloadHeaders{Type: "IGNORE"})
+		result <- err
+	}()
+
+	// wait until the request is on the wire, then hang up
+	<-server.received
+	(<-server.accepted).Close()
+
+	select {
+	case err := <-result:
+		assert.Equal(t, ErrConnectionClosed, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending request was not failed")
+	}
+
+	<-conn.Done()
+	assert.Error(t, conn.Err())
+	assert.NotEqual(t, ErrConnectionClosed, conn.Err())
+	assert.Equal(t, ErrConnectionClosed, channel.Send(PayloadHeaders{Type: "PING"}))
+}
+
+func TestAbortRecordsReason(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	conn := server.connect(t)
+
+	reason := fmt.Errorf("Ping timeout")
+	conn.Abort(reason)
+	conn.Close()
+
+	<-conn.Done()
+	assert.Equal(t, reason, conn.Err())
+}
+
+func TestReconnectKeepsChannels(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	conn := server.connect(t)
+	defer conn.Close()
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+
+	(<-server.accepted).Close()
+	<-conn.Done()
+
+	server.reconnect(t, conn)
+	assert.NoError(t, conn.Err())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := channel.Request(ctx, &PayloadHeaders{Type: "PING"})
+	assert.NoError(t, err)
+}
+
+func TestBinaryPayload(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	conn := server.connect(t)
+	defer conn.Close()
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+	received := make(chan []byte, 1)
+	channel.OnBinaryMessage(func(message *api.CastMessage) {
+		received <- message.PayloadBinary
+	})
+
+	data := []byte{0x00, 0xff, '{', 0x01}
+	require.NoError(t, channel.SendBinary(data))
+
+	select {
+	case echo := <-received:
+		assert.Equal(t, data, echo)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for binary echo")
+	}
+
+	// the receive loop survived the binary frame
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := channel.Request(ctx, &PayloadHeaders{Type: "PING"})
+	assert.NoError(t, err)
+}
+
+func TestConnectionOverPipe(t *testing.T) {
+	client, device := net.Pipe()
+	conn := NewConnectionFromConn(client)
+	defer conn.Close()
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+
+	go func() {
+		var length uint32
+		binary.Read(device, binary.BigEndian, &length)
+		packet := make([]byte, length)
+		io.ReadFull(device, packet)
+		message := &api.CastMessage{}
+		proto.Unmarshal(packet, message)
+
+		payload := `{"type":"PONG","requestId":1}`
+		message.SourceId, message.DestinationId = message.DestinationId, message.SourceId
+		message.PayloadUtf8 = &payload
+		data, _ := proto.Marshal(message)
+		frame := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(frame, uint32(len(data)))
+		copy(frame[4:], data)
+		device.Write(frame)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := channel.Request(ctx, &PayloadHeaders{Type: "PING"})
+	require.NoError(t, err)
+	assert.Equal(t, "receiver-0", reply.GetSourceId())
+
+	// authentication needs the device's TLS certificate
+	_, ok := conn.Authenticate(ctx, nil).(*AuthError)
+	assert.True(t, ok)
+}
+
+func TestCustomDialer(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	dialed := ""
+	conn := NewConnection()
+	conn.Dialer = TLSDialer(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = address
+		return DialTCP(ctx, network, address)
+	}, nil)
+	server.reconnect(t, conn)
+	defer conn.Close()
+
+	assert.Equal(t, server.listener.Addr().String(), dialed)
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := channel.Request(ctx, &PayloadHeaders{Type: "PING"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,54 @@
+package net
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Dialer opens the transport a Connection speaks the Cast protocol over.
+// The returned net.Conn carries the framed messages directly, so any TLS
+// handshake must already have been done - see TLSDialer.
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DefaultTLSConfig is used by Connect when Connection.TLSConfig is nil.
+// Cast devices present self-signed certificates, so they are not verified
+// here; use Authenticate to check the device is genuine.
+var DefaultTLSConfig = &tls.Config{
+	InsecureSkipVerify: true,
+}
+
+// DialTCP is a Dialer that opens a plain TCP connection.
+func DialTCP(ctx context.Context, network, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, address)
+}
+
+// TLSDialer wraps dial, running a TLS client handshake over each connection
+// it opens. A nil dial uses DialTCP, and a nil config DefaultTLSConfig.
+func TLSDialer(dial Dialer, config *tls.Config) Dialer {
+	if dial == nil {
+		dial = DialTCP
+	}
+	if config == nil {
+		config = DefaultTLSConfig
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		deadline, _ := ctx.Deadline()
+		conn.SetDeadline(deadline)
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return tlsConn, nil
+	}
+}
@@ -0,0 +1,164 @@
+package net
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/gogo/protobuf/proto"
+)
+
+// DefaultMaxFrameSize is the largest message the Cast protocol allows.
+const DefaultMaxFrameSize = 64 * 1024
+
+// FrameTooLargeError reports a frame longer than the permitted maximum. On
+// receipt the stream cannot be resynchronised without reading the whole
+// frame, so the connection is dropped.
+type FrameTooLargeError struct {
+	Length uint32
+	Max    uint32
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("Frame of %d bytes exceeds maximum of %d", e.Length, e.Max)
+}
+
+// InvalidMessageError reports a frame that was read intact but does not
+// hold a valid CastMessage. The receive loop skips such frames.
+type InvalidMessageError struct {
+	Reason string
+	Err    error
+}
+
+func (e *InvalidMessageError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Invalid message: %s: %s", e.Reason, e.Err)
+	}
+	return fmt.Sprintf("Invalid message: %s", e.Reason)
+}
+
+func (e *InvalidMessageError) Unwrap() error {
+	return e.Err
+}
+
+// ReadFrame reads one length prefixed frame from r, refusing frames longer
+// than max bytes before allocating for them.
+func ReadFrame(r io.Reader, max uint32) ([]byte, error) {
+	var length uint32
+	err := binary.Read(r, binary.BigEndian, &length)
+	if err == io.EOF {
+		// clean end of stream between frames
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read packet length: %s", err)
+	}
+	if length > max {
+		return nil, &FrameTooLargeError{Length: length, Max: max}
+	}
+
+	packet := make([]byte, length)
+	_, err = io.ReadFull(r, packet)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read packet: %s", err)
+	}
+	return packet, nil
+}
+
+// DecodeMessage parses a frame into a CastMessage, checking that the
+// required fields are present.
+func DecodeMessage(packet []byte) (*api.CastMessage, error) {
+	if len(packet) == 0 {
+		return nil, &InvalidMessageError{Reason: "empty packet"}
+	}
+
+	message := &api.CastMessage{}
+	err := proto.Unmarshal(packet, message)
+	if err != nil {
+		return nil, &InvalidMessageError{Reason: "failed to unmarshal CastMessage", Err: err}
+	}
+
+	if err := validateMessage(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func validateMessage(message *api.CastMessage) error {
+	switch {
+	case message.ProtocolVersion == nil:
+		return &InvalidMessageError{Reason: "missing protocol_version"}
+	case message.SourceId == nil:
+		return &InvalidMessageError{Reason: "missing source_id"}
+	case message.DestinationId == nil:
+		return &InvalidMessageError{Reason: "missing destination_id"}
+	case message.Namespace == nil:
+		return &InvalidMessageError{Reason: "missing namespace"}
+	case message.PayloadType == nil:
+		return &InvalidMessageError{Reason: "missing payload_type"}
+	}
+
+	switch *message.PayloadType {
+	case api.CastMessage_STRING:
+		if message.PayloadUtf8 == nil {
+			return &InvalidMessageError{Reason: "missing payload_utf8"}
+		}
+	case api.CastMessage_BINARY:
+		if message.PayloadBinary == nil {
+			return &InvalidMessageError{Reason: "missing payload_binary"}
+		}
+	default:
+		return &InvalidMessageError{Reason: fmt.Sprintf("unknown payload_type %d", *message.PayloadType)}
+	}
+	return nil
+}
+
+// DecodeHeaders parses the JSON headers of a STRING message. BINARY
+// messages carry no headers, so it returns nil for them.
+func DecodeHeaders(message *api.CastMessage) (*PayloadHeaders, error) {
+	if message.GetPayloadType() == api.CastMessage_BINARY {
+		return nil, nil
+	}
+	headers := &PayloadHeaders{}
+	err := json.Unmarshal([]byte(message.GetPayloadUtf8()), headers)
+	if err != nil {
+		return nil, &InvalidMessageError{Reason: "failed to unmarshal payload headers", Err: err}
+	}
+	return headers, nil
+}
+
+// ReadMessage reads and decodes the next message from r.
+func ReadMessage(r io.Reader, max uint32) (*api.CastMessage, error) {
+	packet, err := ReadFrame(r, max)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeMessage(packet)
+}
+
+// EncodeFrame marshals message with its length prefix.
+func EncodeFrame(message *api.CastMessage) ([]byte, error) {
+	proto.SetDefaults(message)
+
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	return frame, nil
+}
+
+// WriteMessage writes message to w as a single frame.
+func WriteMessage(w io.Writer, message *api.CastMessage) error {
+	frame, err := EncodeFrame(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(frame)
+	return err
+}
@@ -0,0 +1,35 @@
+//go:build go1.18
+// +build go1.18
+
+package net
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzReadMessage(f *testing.F) {
+	valid, _ := EncodeFrame(testMessage(`{"type":"PING","requestId":1}`))
+	f.Add(valid)
+	f.Add(rawFrame(nil))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+	f.Add(rawFrame([]byte{0x08, 0x00, 0x12, 0x01}))
+
+	const max = 1024
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bytes.NewReader(data)
+		for {
+			message, err := ReadMessage(r, max)
+			if _, ok := err.(*InvalidMessageError); ok {
+				continue
+			}
+			if err != nil {
+				return
+			}
+			if err := validateMessage(message); err != nil {
+				t.Fatalf("decoded message fails validation: %s", err)
+			}
+			DecodeHeaders(message)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage(payload string) *api.CastMessage {
+	source, destination, namespace := "receiver-0", "sender-0", testNamespace
+	return &api.CastMessage{
+		ProtocolVersion: api.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        &source,
+		DestinationId:   &destination,
+		Namespace:       &namespace,
+		PayloadType:     api.CastMessage_STRING.Enum(),
+		PayloadUtf8:     &payload,
+	}
+}
+
+func rawFrame(data []byte) []byte {
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	return frame
+}
+
+func TestReadFrameTooLarge(t *testing.T) {
+	frame := []byte{0xff, 0xff, 0xff, 0xff}
+	_, err := ReadFrame(bytes.NewReader(frame), DefaultMaxFrameSize)
+	tooLarge, ok := err.(*FrameTooLargeError)
+	require.True(t, ok, "expected *FrameTooLargeError, got %v", err)
+	assert.Equal(t, uint32(0xffffffff), tooLarge.Length)
+}
+
+func TestDecodeMessageMissingFields(t *testing.T) {
+	message := testMessage(`{"type":"PING"}`)
+	message.SourceId = nil
+	// the marshaller complains about the missing field but still encodes
+	data, _ := proto.Marshal(message)
+
+	_, err := DecodeMessage(data)
+	_, ok := err.(*InvalidMessageError)
+	assert.True(t, ok, "expected *InvalidMessageError, got %v", err)
+
+	message = testMessage(`{"type":"PING"}`)
+	message.PayloadUtf8 = nil
+	data, _ = proto.Marshal(message)
+	_, err = DecodeMessage(data)
+	_, ok = err.(*InvalidMessageError)
+	assert.True(t, ok, "expected *InvalidMessageError, got %v", err)
+}
+
+func TestReceiveLoopSkipsInvalidFrames(t *testing.T) {
+	client, device := net.Pipe()
+	conn := NewConnectionFromConn(client)
+	defer conn.Close()
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+	received := make(chan *api.CastMessage, 1)
+	channel.OnMessage("STATUS", func(message *api.CastMessage) {
+		received <- message
+	})
+
+	go func() {
+		device.Write(rawFrame(nil))
+		device.Write(rawFrame([]byte{0xde, 0xad, 0xbe, 0xef}))
+		noSource := testMessage(`{"type":"STATUS"}`)
+		noSource.SourceId = nil
+		data, _ := proto.Marshal(noSource)
+		device.Write(rawFrame(data))
+		WriteMessage(device, testMessage(`{not json`))
+		WriteMessage(device, testMessage(`{"type":"STATUS"}`))
+	}()
+
+	select {
+	case message := <-received:
+		assert.Equal(t, `{"type":"STATUS"}`, message.GetPayloadUtf8())
+	case <-time.After(5 * time.Second):
+		t.Fatal("valid frame after invalid ones was not delivered")
+	}
+	assert.NoError(t, conn.Err())
+}
+
+func TestReceiveLoopDropsOversizedFrame(t *testing.T) {
+	client, device := net.Pipe()
+	conn := NewConnectionFromConn(client)
+	conn.MaxFrameSize = 16
+	defer conn.Close()
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+	go func() {
+		WriteMessage(device, testMessage(`{"type":"STATUS","padding":"xxxxxxxxxxxxxxxx"}`))
+	}()
+
+	select {
+	case <-conn.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not dropped")
+	}
+	_, ok := conn.Err().(*FrameTooLargeError)
+	assert.True(t, ok, "expected *FrameTooLargeError, got %v", conn.Err())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := channel.Request(ctx, &PayloadHeaders{Type: "PING"})
+	assert.Equal(t, ErrConnectionClosed, err)
+}
@@ -0,0 +1,98 @@
+package net
+
+import (
+	"time"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/log"
+)
+
+// Direction is the way a message travels through a Connection.
+type Direction int
+
+const (
+	Inbound Direction = iota
+	Outbound
+)
+
+func (d Direction) String() string {
+	if d == Outbound {
+		return "outbound"
+	}
+	return "inbound"
+}
+
+// Envelope is a message passing through a Connection, as seen by an
+// Interceptor.
+type Envelope struct {
+	Direction Direction
+	Time      time.Time
+	Message   *api.CastMessage
+	// Headers are parsed from a STRING payload, nil for BINARY or if the
+	// payload is not valid JSON.
+	Headers *PayloadHeaders
+}
+
+// Interceptor sees every message in one direction on a Connection. It may
+// rewrite the message in place, and returns false to drop it.
+type Interceptor func(envelope *Envelope) bool
+
+// AddInterceptor registers interceptor for messages travelling in
+// direction. Interceptors run in the order they were added, on the
+// goroutine receiving or sending the message.
+func (c *Connection) AddInterceptor(direction Direction, interceptor Interceptor) {
+	c.interceptorsLock.Lock()
+	defer c.interceptorsLock.Unlock()
+	if c.interceptors == nil {
+		c.interceptors = make(map[Direction][]Interceptor)
+	}
+	// copy on write so intercept can iterate a snapshot without the lock
+	current := c.interceptors[direction]
+	interceptors := make([]Interceptor, len(current), len(current)+1)
+	copy(interceptors, current)
+	c.interceptors[direction] = append(interceptors, interceptor)
+}
+
+// intercept runs the interceptors for direction over message, returning the
+// message's headers afterwards, and false if it was dropped. Nil headers
+// are parsed from the payload if there are interceptors to see them.
+func (c *Connection) intercept(direction Direction, message *api.CastMessage, headers *PayloadHeaders) (*PayloadHeaders, bool) {
+	c.interceptorsLock.RLock()
+	interceptors := c.interceptors[direction]
+	c.interceptorsLock.RUnlock()
+	if len(interceptors) == 0 {
+		return headers, true
+	}
+	if headers == nil {
+		headers, _ = DecodeHeaders(message)
+	}
+
+	envelope := &Envelope{
+		Direction: direction,
+		Time:      time.Now(),
+		Message:   message,
+		Headers:   headers,
+	}
+	payload := message.GetPayloadUtf8()
+	for _, interceptor := range interceptors {
+		if !interceptor(envelope) {
+			log.Printf("%s message dropped by interceptor: %s", direction, message)
+			return nil, false
+		}
+	}
+
+	// a rewritten message has to be as well formed as one off the wire
+	if err := validateMessage(message); err != nil {
+		log.Errorf("Dropping %s message rewritten by interceptor: %s", direction, err)
+		return nil, false
+	}
+	if message.GetPayloadUtf8() == payload && message.GetPayloadType() != api.CastMessage_BINARY {
+		return envelope.Headers, true
+	}
+	headers, err := DecodeHeaders(message)
+	if err != nil {
+		log.Errorf("Dropping %s message rewritten by interceptor: %s", direction, err)
+		return nil, false
+	}
+	return headers, true
+}
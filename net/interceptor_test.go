@@ -0,0 +1,123 @@
+package net
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptors(t *testing.T) {
+	client, device := net.Pipe()
+	conn := NewConnectionFromConn(client)
+	defer conn.Close()
+
+	var lock sync.Mutex
+	var seen []string
+	observe := func(envelope *Envelope) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		assert.False(t, envelope.Time.IsZero())
+		seen = append(seen, envelope.Direction.String()+" "+envelope.Headers.Type)
+		return true
+	}
+	conn.AddInterceptor(Inbound, observe)
+	conn.AddInterceptor(Outbound, observe)
+
+	// rewrite inbound STATUS into RENAMED, drop outbound SECRET
+	conn.AddInterceptor(Inbound, func(envelope *Envelope) bool {
+		if envelope.Headers.Type == "STATUS" {
+			payload := `{"type":"RENAMED"}`
+			envelope.Message.PayloadUtf8 = &payload
+		}
+		return true
+	})
+	conn.AddInterceptor(Outbound, func(envelope *Envelope) bool {
+		return envelope.Headers.Type != "SECRET"
+	})
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+	received := make(chan string, 2)
+	channel.OnMessage("STATUS", func(*api.CastMessage) { received <- "STATUS" })
+	channel.OnMessage("RENAMED", func(*api.CastMessage) { received <- "RENAMED" })
+
+	outbound := make(chan string, 2)
+	go func() {
+		for {
+			message, err := ReadMessage(device, DefaultMaxFrameSize)
+			if err != nil {
+				return
+			}
+			outbound <- message.GetPayloadUtf8()
+		}
+	}()
+
+	require.NoError(t, channel.Send(PayloadHeaders{Type: "SECRET"}))
+	require.NoError(t, channel.Send(PayloadHeaders{Type: "HELLO"}))
+	select {
+	case payload := <-outbound:
+		assert.Equal(t, `{"type":"HELLO"}`, payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("outbound message not sent")
+	}
+
+	go WriteMessage(device, testMessage(`{"type":"STATUS"}`))
+	select {
+	case name := <-received:
+		assert.Equal(t, "RENAMED", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("inbound message not delivered")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"outbound SECRET", "outbound HELLO", "inbound STATUS"}, seen)
+}
+
+func TestInterceptorDropsInbound(t *testing.T) {
+	client, device := net.Pipe()
+	conn := NewConnectionFromConn(client)
+	defer conn.Close()
+
+	conn.AddInterceptor(Inbound, func(envelope *Envelope) bool {
+		return envelope.Headers.RequestId == nil
+	})
+
+	channel := conn.NewChannel("sender-0", "receiver-0", testNamespace)
+	go func() {
+		ReadMessage(device, DefaultMaxFrameSize)
+		WriteMessage(device, testMessage(`{"type":"PONG","requestId":1}`))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := channel.Request(ctx, &PayloadHeaders{Type: "PING"})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestInterceptorSeesInvalidPayload(t *testing.T) {
+	client, device := net.Pipe()
+	conn := NewConnectionFromConn(client)
+	defer conn.Close()
+
+	seen := make(chan *Envelope, 1)
+	conn.AddInterceptor(Inbound, func(envelope *Envelope) bool {
+		seen <- envelope
+		return false
+	})
+	go WriteMessage(device, testMessage(`not json`))
+
+	select {
+	case envelope := <-seen:
+		assert.Equal(t, "not json", envelope.Message.GetPayloadUtf8())
+		assert.Nil(t, envelope.Headers)
+	case <-time.After(5 * time.Second):
+		t.Fatal("interceptor did not see the frame")
+	}
+}
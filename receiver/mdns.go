@@ -0,0 +1,51 @@
+package receiver
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/hashicorp/mdns"
+)
+
+const mdnsService = "_googlecast._tcp"
+
+// Advertise announces the receiver as a Cast device on port over mDNS, so
+// that senders and discovery.Service find it. Call Shutdown on the returned
+// server to withdraw it.
+func (r *Receiver) Advertise(port int) (*mdns.Server, error) {
+	txt := []string{
+		"id=" + r.UUID,
+		"fn=" + r.Name,
+		"md=" + r.Model,
+		"ve=05",
+		"ca=4101",
+		"st=0",
+		"rs=",
+	}
+	// the instance name only needs to be unique on the network
+	service, err := mdns.NewMDNSService(r.UUID, mdnsService, "", "", port, ipv4Addrs(), txt)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create mDNS service: %s", err)
+	}
+	server, err := mdns.NewServer(&mdns.Config{Zone: service})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start mDNS server: %s", err)
+	}
+	return server, nil
+}
+
+// ipv4Addrs returns the addresses to advertise, or nil to let mdns look up
+// the hostname.
+func ipv4Addrs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			ips = append(ips, ipnet.IP)
+		}
+	}
+	return ips
+}
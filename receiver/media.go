@@ -0,0 +1,152 @@
+package receiver
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/barnybug/go-cast/internal/device"
+	castnet "github.com/barnybug/go-cast/net"
+)
+
+const supportedMediaCommands = controllers.CommandPause
+
+// mediaSession is the media loaded in the default media receiver.
+type mediaSession struct {
+	id          int
+	media       controllers.MediaStatusMedia
+	playerState string
+	idleReason  string
+	// position is the playback position at since
+	position float64
+	since    time.Time
+}
+
+func (m *mediaSession) currentTime() float64 {
+	if m.playerState != "PLAYING" {
+		return m.position
+	}
+	return m.position + time.Since(m.since).Seconds()
+}
+
+func (m *mediaSession) setState(playerState string) {
+	m.position = m.currentTime()
+	m.since = time.Now()
+	m.playerState = playerState
+}
+
+// Idle tells senders that playback of the loaded media has ended by itself,
+// with reason "FINISHED" or "ERROR".
+func (r *Receiver) Idle(reason string) {
+	r.device.Lock()
+	defer r.device.Unlock()
+	if r.media == nil {
+		return
+	}
+	r.media.setState("IDLE")
+	r.media.idleReason = reason
+	r.device.BroadcastMedia(r.mediaStatus(nil))
+}
+
+// mediaStatus builds a MEDIA_STATUS payload. Must be called with the lock
+// held.
+func (r *Receiver) mediaStatus(requestId *int) interface{} {
+	m := r.media
+	if m == nil {
+		return device.MediaStatus(requestId)
+	}
+	return device.MediaStatus(requestId, &controllers.MediaStatus{
+		MediaSessionID:         m.id,
+		PlaybackRate:           1,
+		PlayerState:            m.playerState,
+		CurrentTime:            m.currentTime(),
+		SupportedMediaCommands: supportedMediaCommands,
+		Volume:                 &r.device.Volume,
+		Media:                  &m.media,
+		IdleReason:             m.idleReason,
+	})
+}
+
+// mediaCommand handles a message on the media namespace. Must be called
+// with the lock held.
+func (r *Receiver) mediaCommand(p *device.Peer, message *api.CastMessage, headers *castnet.PayloadHeaders, payload []byte) {
+	switch headers.Type {
+	case "GET_STATUS":
+		p.Send(device.Reply(message, r.mediaStatus(headers.RequestId)))
+		return
+	case "LOAD":
+		var request controllers.LoadMediaCommand
+		json.Unmarshal(payload, &request)
+		if err := r.handler.Load(request.Media, float64(request.CurrentTime), request.Autoplay); err != nil {
+			p.Send(device.Reply(message, &device.ErrorResponse{
+				PayloadHeaders: castnet.PayloadHeaders{Type: "LOAD_FAILED", RequestId: headers.RequestId},
+			}))
+			return
+		}
+		r.load(&request)
+		r.device.Respond(p, message, headers, r.mediaStatus)
+		return
+	}
+
+	var request controllers.MediaCommand
+	json.Unmarshal(payload, &request)
+	m := r.media
+	if m == nil || request.MediaSessionID != m.id {
+		p.Send(device.Reply(message, device.InvalidRequest(headers, "INVALID_MEDIA_SESSION_ID")))
+		return
+	}
+
+	var err error
+	switch headers.Type {
+	case "PLAY":
+		if err = r.handler.Play(); err == nil {
+			m.setState("PLAYING")
+		}
+	case "PAUSE":
+		if err = r.handler.Pause(); err == nil {
+			m.setState("PAUSED")
+		}
+	case "STOP":
+		if err = r.handler.Stop(); err == nil {
+			m.setState("IDLE")
+			m.idleReason = "CANCELLED"
+		}
+	default:
+		p.Send(device.Reply(message, device.InvalidRequest(headers, "INVALID_COMMAND")))
+		return
+	}
+	if err != nil {
+		p.Send(device.Reply(message, device.InvalidRequest(headers, "INVALID_COMMAND")))
+		return
+	}
+	r.device.Respond(p, message, headers, r.mediaStatus)
+}
+
+// load records the loaded media. Must be called with the lock held.
+func (r *Receiver) load(request *controllers.LoadMediaCommand) {
+	id := 1
+	if r.media != nil {
+		id = r.media.id + 1
+	}
+	playerState := "PAUSED"
+	if request.Autoplay {
+		playerState = "PLAYING"
+	}
+	r.media = &mediaSession{
+		id: id,
+		media: controllers.MediaStatusMedia{
+			ContentId:      request.Media.ContentId,
+			StreamType:     request.Media.StreamType,
+			ContentType:    request.Media.ContentType,
+			Metadata:       request.Media.Metadata,
+			Tracks:         request.Media.Tracks,
+			TextTrackStyle: request.Media.TextTrackStyle,
+		},
+		playerState: playerState,
+		position:    float64(request.CurrentTime),
+		since:       time.Now(),
+	}
+	statusText := "Now Casting: " + request.Media.ContentId
+	r.device.App.StatusText = &statusText
+}
@@ -0,0 +1,168 @@
+// Package receiver implements the device side of the Cast protocol, so that
+// a Go program can be cast to.
+//
+// A Receiver accepts CastV2 connections from senders, answers the platform
+// namespaces (connection, heartbeat and receiver) and the default media
+// receiver's media namespace, and hands the requests it cannot answer itself
+// to a Handler.
+package receiver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/barnybug/go-cast/internal/device"
+	castnet "github.com/barnybug/go-cast/net"
+)
+
+const DefaultPort = 8009
+
+// Handler carries out the requests senders make of a Receiver. Returning an
+// error rejects the request. Methods are called one at a time, and must not
+// call back into the Receiver.
+type Handler interface {
+	// Launch starts appId, replacing any running app.
+	Launch(appId string) error
+	// Quit stops the running app.
+	Quit(appId string) error
+	// Load starts playing media from currentTime seconds, or loads it
+	// paused if autoplay is false.
+	Load(media controllers.MediaItem, currentTime float64, autoplay bool) error
+	Play() error
+	Pause() error
+	// Stop ends playback of the loaded media.
+	Stop() error
+	// SetVolume changes the device volume. Only the fields that are set
+	// change.
+	SetVolume(volume controllers.Volume) error
+}
+
+// Receiver is a Cast device.
+type Receiver struct {
+	// Name is the friendly name shown to senders.
+	Name string
+	// UUID identifies the device. It defaults to a random id.
+	UUID string
+	// Model is advertised to senders.
+	Model string
+	// TLSConfig is used to accept connections. If nil, a self-signed
+	// certificate is generated.
+	TLSConfig *tls.Config
+
+	handler Handler
+	device  *device.Device
+
+	// guarded by the device lock
+	media *mediaSession
+}
+
+func New(name string, handler Handler) *Receiver {
+	r := &Receiver{
+		Name:    name,
+		UUID:    device.RandomId(),
+		Model:   "go-cast",
+		handler: handler,
+	}
+	r.device = device.New(platform{r})
+	return r
+}
+
+// GenerateCertificate creates a self-signed certificate for a receiver.
+// Senders that authenticate the device will reject it.
+func GenerateCertificate(name string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// ListenAndServe listens on addr, ":8009" if empty, and serves senders
+// until ctx is cancelled.
+func (r *Receiver) ListenAndServe(ctx context.Context, addr string) error {
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", DefaultPort)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("Failed to listen: %s", err)
+	}
+	return r.Serve(ctx, listener)
+}
+
+// Serve accepts connections on listener until ctx is cancelled, running
+// the TLS handshake itself. The listener is closed on return.
+func (r *Receiver) Serve(ctx context.Context, listener net.Listener) error {
+	config := r.TLSConfig
+	if config == nil {
+		certificate, err := GenerateCertificate(r.Name)
+		if err != nil {
+			return fmt.Errorf("Failed to create certificate: %s", err)
+		}
+		config = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	}
+	listener = tls.NewListener(listener, config)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		r.device.Disconnect()
+	}()
+
+	err := r.device.Serve(listener)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// platform adapts a Receiver's Handler to its device.
+type platform struct {
+	*Receiver
+}
+
+func (p platform) Launch(app *controllers.ApplicationSession) error {
+	if err := p.handler.Launch(*app.AppID); err != nil {
+		return err
+	}
+	p.media = nil
+	return nil
+}
+
+func (p platform) Quit(app *controllers.ApplicationSession) error {
+	if err := p.handler.Quit(*app.AppID); err != nil {
+		return err
+	}
+	p.media = nil
+	return nil
+}
+
+func (p platform) SetVolume(volume controllers.Volume) error {
+	return p.handler.SetVolume(volume)
+}
+
+func (p platform) Media(peer *device.Peer, message *api.CastMessage, headers *castnet.PayloadHeaders, payload []byte) {
+	p.mediaCommand(peer, message, headers, payload)
+}
@@ -0,0 +1,103 @@
+package receiver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast"
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a Handler that records the requests it accepts.
+type recorder struct {
+	lock     sync.Mutex
+	requests []string
+}
+
+func (h *recorder) record(format string, args ...interface{}) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.requests = append(h.requests, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (h *recorder) Launch(appId string) error {
+	if appId != cast.AppMedia {
+		return errors.New("unsupported app")
+	}
+	return h.record("launch %s", appId)
+}
+
+func (h *recorder) Quit(appId string) error { return h.record("quit %s", appId) }
+
+func (h *recorder) Load(media controllers.MediaItem, currentTime float64, autoplay bool) error {
+	return h.record("load %s %v %v", media.ContentId, currentTime, autoplay)
+}
+
+func (h *recorder) Play() error  { return h.record("play") }
+func (h *recorder) Pause() error { return h.record("pause") }
+func (h *recorder) Stop() error  { return h.record("stop") }
+
+func (h *recorder) SetVolume(volume controllers.Volume) error {
+	return h.record("volume %v", *volume.Level)
+}
+
+func TestReceiver(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	handler := &recorder{}
+	r := New("Speakers", handler)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go r.Serve(ctx, listener)
+
+	addr := listener.Addr().(*net.TCPAddr)
+	client := cast.NewClient(addr.IP, addr.Port)
+	require.NoError(t, client.Connect(ctx))
+	defer client.Close()
+
+	_, err = client.Receiver().LaunchApp(ctx, cast.AppURL)
+	var responseErr *controllers.ResponseError
+	require.True(t, errors.As(err, &responseErr))
+	assert.Equal(t, "LAUNCH_ERROR", responseErr.Type)
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	item := controllers.MediaItem{ContentId: "http://example.com/song.mp3", StreamType: "BUFFERED", ContentType: "audio/mpeg"}
+	_, err = media.LoadMedia(ctx, item, 0, true, nil)
+	require.NoError(t, err)
+	assert.True(t, client.IsPlaying(ctx))
+
+	_, err = media.Pause(ctx)
+	require.NoError(t, err)
+	_, err = media.Play(ctx)
+	require.NoError(t, err)
+	_, err = media.Seek(ctx, 10, "")
+	var unsupportedErr *controllers.UnsupportedCommandError
+	require.True(t, errors.As(err, &unsupportedErr))
+	assert.Equal(t, controllers.CommandSeek, unsupportedErr.Command)
+
+	level := 0.5
+	_, err = client.Receiver().SetVolume(ctx, &controllers.Volume{Level: &level})
+	require.NoError(t, err)
+	_, err = client.Receiver().QuitApp(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"launch CC1AD845",
+		"load http://example.com/song.mp3 0 true",
+		"pause",
+		"play",
+		"volume 0.5",
+		"quit CC1AD845",
+	}, handler.requests)
+}
@@ -0,0 +1,173 @@
+package cast
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/barnybug/go-cast/events"
+	"github.com/barnybug/go-cast/log"
+	castnet "github.com/barnybug/go-cast/net"
+)
+
+// ReconnectPolicy controls how a Client re-establishes a dropped connection.
+type ReconnectPolicy struct {
+	// Backoff is the delay before the first attempt. It doubles after every
+	// failed attempt, up to MaxBackoff. Zero uses DefaultReconnectPolicy's.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of attempts before giving up and emitting
+	// events.Disconnected. Zero retries forever.
+	MaxAttempts int
+	// Timeout bounds each attempt to dial and restore the session.
+	Timeout time.Duration
+}
+
+var DefaultReconnectPolicy = ReconnectPolicy{
+	Backoff:    time.Second,
+	MaxBackoff: 30 * time.Second,
+	Timeout:    15 * time.Second,
+}
+
+// SetReconnectPolicy enables automatic reconnection when the connection to
+// the device drops. Pass nil to disable it again.
+func (c *Client) SetReconnectPolicy(policy *ReconnectPolicy) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.reconnect = policy
+}
+
+// reconnectLoop retries restore according to policy, emitting Reconnecting
+// before and Reconnected after a successful attempt. It returns the last
+// error if it gives up.
+func (c *Client) reconnectLoop(ctx context.Context, policy *ReconnectPolicy, reason error) error {
+	// the old heartbeat must not touch the connection while it is re-dialled
+	c.heartbeat.Stop()
+
+	backoff := policy.Backoff
+	if backoff <= 0 {
+		// never re-dial in a tight loop
+		backoff = DefaultReconnectPolicy.Backoff
+	}
+	for attempt := 1; policy.MaxAttempts == 0 || attempt <= policy.MaxAttempts; attempt++ {
+		c.sendEvent(ctx, events.Reconnecting{Attempt: attempt, Reason: reason})
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		attemptCtx := ctx
+		cancel := func() {}
+		if policy.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		}
+		reason = c.restore(ctx, attemptCtx)
+		cancel()
+		if reason == nil {
+			c.sendEvent(ctx, events.Reconnected{Attempts: attempt})
+			return nil
+		}
+		log.Printf("Reconnect attempt %d failed: %s", attempt, reason)
+
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+	return reason
+}
+
+// restore re-dials the device and re-opens the virtual connections the
+// client had, re-attaching the media and url controllers to their app
+// sessions if they are still running. ctx is the lifetime of the client,
+// attemptCtx bounds this attempt.
+func (c *Client) restore(ctx, attemptCtx context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn == nil {
+		// closed while we were waiting
+		return castnet.ErrConnectionClosed
+	}
+	if err := c.dial(attemptCtx); err != nil {
+		return err
+	}
+	if err := c.connection.Start(attemptCtx); err != nil {
+		return err
+	}
+	if err := c.heartbeat.Start(ctx); err != nil {
+		return err
+	}
+	if c.media == nil && c.url == nil {
+		return nil
+	}
+
+	status, err := c.receiver.GetStatus(attemptCtx)
+	if err != nil {
+		return err
+	}
+
+	if c.media != nil {
+		var transportId string
+		// the app may not be the default media receiver if it was attached to
+		app := status.GetSessionByNamespace(controllers.NamespaceMedia)
+		c.mediaConnection, transportId, err = c.reattach(attemptCtx, app, c.media.DestinationID, c.mediaConnection)
+		if err != nil {
+			return err
+		}
+		if c.mediaConnection == nil {
+			c.media.Close()
+			c.media, c.mediaApp = nil, ""
+		} else {
+			c.mediaApp = *app.AppID
+			if transportId != c.media.DestinationID {
+				c.media.SetDestinationID(transportId)
+			}
+			if err := c.media.Start(attemptCtx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.url != nil {
+		var transportId string
+		c.urlConnection, transportId, err = c.reattach(attemptCtx, status.GetSessionByAppId(AppURL), c.url.DestinationID, c.urlConnection)
+		if err != nil {
+			return err
+		}
+		if c.urlConnection == nil {
+			c.url.Close()
+			c.url = nil
+		} else if transportId != c.url.DestinationID {
+			c.url.SetDestinationID(transportId)
+		}
+	}
+
+	return nil
+}
+
+// reattach re-sends CONNECT to the transport of app, returning the
+// connection controller and transport id to use. It returns a nil controller
+// if app is nil, as it is no longer running, so the next call to Media or URL
+// launches it afresh.
+func (c *Client) reattach(ctx context.Context, app *controllers.ApplicationSession, transportId string, connection *controllers.ConnectionController) (*controllers.ConnectionController, string, error) {
+	if app == nil || app.TransportId == nil {
+		if connection != nil {
+			connection.Close()
+		}
+		return nil, "", nil
+	}
+	if *app.TransportId != transportId || connection == nil {
+		if connection != nil {
+			connection.Close()
+		}
+		connection = controllers.NewConnectionController(c.conn, c.Events, DefaultSender, *app.TransportId)
+	}
+	if err := connection.Start(ctx); err != nil {
+		return nil, "", err
+	}
+	return connection, *app.TransportId, nil
+}
@@ -0,0 +1,121 @@
+// Package record captures the messages exchanged over a Cast connection as
+// JSON Lines, and replays a captured session to a client in place of the
+// device.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/net"
+)
+
+// Entry is one recorded message.
+type Entry struct {
+	Time          time.Time `json:"time"`
+	Direction     string    `json:"direction"`
+	SourceId      string    `json:"sourceId"`
+	DestinationId string    `json:"destinationId"`
+	Namespace     string    `json:"namespace"`
+	PayloadUtf8   *string   `json:"payloadUtf8,omitempty"`
+	PayloadBinary []byte    `json:"payloadBinary,omitempty"`
+}
+
+func NewEntry(envelope *net.Envelope) Entry {
+	message := envelope.Message
+	entry := Entry{
+		Time:          envelope.Time,
+		Direction:     envelope.Direction.String(),
+		SourceId:      message.GetSourceId(),
+		DestinationId: message.GetDestinationId(),
+		Namespace:     message.GetNamespace(),
+	}
+	if message.GetPayloadType() == api.CastMessage_BINARY {
+		entry.PayloadBinary = message.PayloadBinary
+	} else {
+		payload := message.GetPayloadUtf8()
+		entry.PayloadUtf8 = &payload
+	}
+	return entry
+}
+
+func (e *Entry) Inbound() bool {
+	return e.Direction == net.Inbound.String()
+}
+
+// Message rebuilds the CastMessage that was recorded.
+func (e *Entry) Message() *api.CastMessage {
+	sourceId, destinationId, namespace := e.SourceId, e.DestinationId, e.Namespace
+	message := &api.CastMessage{
+		ProtocolVersion: api.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        &sourceId,
+		DestinationId:   &destinationId,
+		Namespace:       &namespace,
+	}
+	if e.PayloadUtf8 != nil {
+		payload := *e.PayloadUtf8
+		message.PayloadType = api.CastMessage_STRING.Enum()
+		message.PayloadUtf8 = &payload
+	} else {
+		message.PayloadType = api.CastMessage_BINARY.Enum()
+		message.PayloadBinary = append([]byte{}, e.PayloadBinary...)
+	}
+	return message
+}
+
+// Recorder writes every message it sees to w, one JSON object per line.
+type Recorder struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w)}
+}
+
+// Attach records the messages travelling in both directions on conn.
+func (r *Recorder) Attach(conn *net.Connection) {
+	conn.AddInterceptor(net.Inbound, r.Record)
+	conn.AddInterceptor(net.Outbound, r.Record)
+}
+
+// Record is a net.Interceptor that writes the message and lets it through.
+func (r *Recorder) Record(envelope *net.Envelope) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err == nil {
+		r.err = r.encoder.Encode(NewEntry(envelope))
+	}
+	return true
+}
+
+// Err returns the first error writing the recording.
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// ReadSession reads a recording written by a Recorder.
+func ReadSession(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	// escaping can make a line several times the size of the frame
+	scanner.Buffer(nil, 8*net.DefaultMaxFrameSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,100 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	gonet "net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast"
+	"github.com/barnybug/go-cast/controllers"
+	"github.com/barnybug/go-cast/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFixture(t *testing.T) []Entry {
+	f, err := os.Open("testdata/session.jsonl")
+	require.NoError(t, err)
+	defer f.Close()
+	entries, err := ReadSession(f)
+	require.NoError(t, err)
+	return entries
+}
+
+func summary(entries []Entry) []string {
+	var lines []string
+	for _, entry := range entries {
+		if entry.Namespace == namespaceHeartbeat {
+			continue
+		}
+		headers := entryHeaders(&entry)
+		lines = append(lines, entry.Direction+" "+entry.DestinationId+" "+headers.Type)
+	}
+	return lines
+}
+
+func TestReplayToClient(t *testing.T) {
+	entries := readFixture(t)
+	replayer := NewReplayer(entries)
+
+	var recording bytes.Buffer
+	recorder := NewRecorder(&recording)
+
+	client := cast.NewClient(gonet.IPv4(127, 0, 0, 1), 8009)
+	client.SetDialer(replayer.Dialer())
+	client.AddInterceptor(net.Inbound, recorder.Record)
+	client.AddInterceptor(net.Outbound, recorder.Record)
+	go func() {
+		for range client.Events {
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, client.Connect(ctx))
+	defer client.Close()
+
+	media, err := client.Media(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "web-5", media.DestinationID)
+
+	item := controllers.MediaItem{
+		ContentId:   "http://example.com/track.mp3",
+		StreamType:  "BUFFERED",
+		ContentType: "audio/mpeg",
+	}
+	message, err := media.LoadMedia(ctx, item, 0, true, map[string]interface{}{})
+	require.NoError(t, err)
+	var loaded controllers.MediaStatusResponse
+	require.NoError(t, json.Unmarshal([]byte(message.GetPayloadUtf8()), &loaded))
+	assert.Equal(t, "PLAYING", loaded.Status[0].PlayerState)
+	assert.Equal(t, 1, loaded.Status[0].MediaSessionID)
+
+	message, err = media.Pause(ctx)
+	require.NoError(t, err)
+	var paused controllers.MediaStatusResponse
+	require.NoError(t, json.Unmarshal([]byte(message.GetPayloadUtf8()), &paused))
+	assert.Equal(t, "PAUSED", paused.Status[0].PlayerState)
+
+	assert.Empty(t, replayer.Unmatched())
+
+	// the recording of the replay matches the original session
+	require.NoError(t, recorder.Err())
+	recorded, err := ReadSession(&recording)
+	require.NoError(t, err)
+	assert.Equal(t, summary(entries), summary(recorded))
+}
+
+func TestRewriteRequestId(t *testing.T) {
+	lookup := func(id int) (int, bool) {
+		return id + 100, id == 7
+	}
+	assert.Equal(t, `{"requestId":107,"type":"RECEIVER_STATUS"}`, rewriteRequestId(`{"type":"RECEIVER_STATUS","requestId":7}`, lookup))
+	assert.Equal(t, `{"type":"MEDIA_STATUS","requestId":0}`, rewriteRequestId(`{"type":"MEDIA_STATUS","requestId":0}`, lookup))
+	assert.Equal(t, `not json`, rewriteRequestId(`not json`, lookup))
+}
@@ -0,0 +1,204 @@
+package record
+
+import (
+	"encoding/json"
+	"io"
+	gonet "net"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/barnybug/go-cast/api"
+	"github.com/barnybug/go-cast/log"
+	"github.com/barnybug/go-cast/net"
+)
+
+const namespaceHeartbeat = "urn:x-cast:com.google.cast.tp.heartbeat"
+
+// Replayer plays the device side of a recorded session. Each message the
+// client sends is matched to the next recorded outbound message with the
+// same namespace, destination and type, and the inbound messages recorded
+// after it are sent back, with request ids rewritten to match the client's.
+// Recorded timing is ignored, and heartbeats are answered directly rather
+// than replayed, so the replay is deterministic.
+type Replayer struct {
+	entries []Entry
+
+	lock      sync.Mutex
+	unmatched []*api.CastMessage
+}
+
+func NewReplayer(entries []Entry) *Replayer {
+	filtered := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Namespace != namespaceHeartbeat {
+			filtered = append(filtered, entry)
+		}
+	}
+	return &Replayer{entries: filtered}
+}
+
+// Dialer returns a net.Dialer that serves the recording over an in-memory
+// pipe, for use with cast.Client.SetDialer.
+func (r *Replayer) Dialer() net.Dialer {
+	return func(ctx context.Context, network, address string) (gonet.Conn, error) {
+		client, device := gonet.Pipe()
+		go r.Serve(device)
+		return client, nil
+	}
+}
+
+// Unmatched returns the messages the client sent that had no counterpart
+// left in the recording.
+func (r *Replayer) Unmatched() []*api.CastMessage {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]*api.CastMessage{}, r.unmatched...)
+}
+
+// Serve replays the session over conn until the client hangs up.
+func (r *Replayer) Serve(conn gonet.Conn) error {
+	defer conn.Close()
+
+	requestIds := map[requestKey]int{}
+	cursor, err := r.play(conn, 0, requestIds)
+	if err != nil {
+		return err
+	}
+
+	for {
+		message, err := net.ReadMessage(conn, net.DefaultMaxFrameSize)
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*net.InvalidMessageError); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		headers, _ := net.DecodeHeaders(message)
+
+		if message.GetNamespace() == namespaceHeartbeat {
+			if headers != nil && headers.Type == "PING" {
+				err = r.send(conn, reply(message, `{"type":"PONG"}`))
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		i := r.match(cursor, message, headers)
+		if i < 0 {
+			log.Printf("Replay: no recorded counterpart for %s", message)
+			r.lock.Lock()
+			r.unmatched = append(r.unmatched, message)
+			r.lock.Unlock()
+			continue
+		}
+
+		if recorded := entryHeaders(&r.entries[i]); recorded != nil && recorded.RequestId != nil && headers.RequestId != nil {
+			key := requestKey{message.GetNamespace(), message.GetDestinationId(), *recorded.RequestId}
+			requestIds[key] = *headers.RequestId
+		}
+		cursor, err = r.play(conn, i+1, requestIds)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// match finds the next outbound entry from cursor that corresponds to
+// message, returning -1 if there is none.
+func (r *Replayer) match(cursor int, message *api.CastMessage, headers *net.PayloadHeaders) int {
+	for i := cursor; i < len(r.entries); i++ {
+		entry := &r.entries[i]
+		if entry.Inbound() || entry.Namespace != message.GetNamespace() || entry.DestinationId != message.GetDestinationId() {
+			continue
+		}
+		recorded := entryHeaders(entry)
+		if (recorded == nil) != (headers == nil) {
+			continue
+		}
+		if recorded == nil || recorded.Type == headers.Type {
+			return i
+		}
+	}
+	return -1
+}
+
+// play sends the inbound entries from cursor up to the next outbound entry,
+// returning the index of that entry.
+func (r *Replayer) play(conn gonet.Conn, cursor int, requestIds map[requestKey]int) (int, error) {
+	for ; cursor < len(r.entries) && r.entries[cursor].Inbound(); cursor++ {
+		entry := &r.entries[cursor]
+		message := entry.Message()
+		if message.PayloadUtf8 != nil {
+			payload := rewriteRequestId(*message.PayloadUtf8, func(id int) (int, bool) {
+				actual, ok := requestIds[requestKey{entry.Namespace, entry.SourceId, id}]
+				return actual, ok
+			})
+			message.PayloadUtf8 = &payload
+		}
+		if err := r.send(conn, message); err != nil {
+			return cursor, err
+		}
+	}
+	return cursor, nil
+}
+
+func (r *Replayer) send(conn gonet.Conn, message *api.CastMessage) error {
+	return net.WriteMessage(conn, message)
+}
+
+func entryHeaders(entry *Entry) *net.PayloadHeaders {
+	if entry.PayloadUtf8 == nil {
+		return nil
+	}
+	headers := &net.PayloadHeaders{}
+	if err := json.Unmarshal([]byte(*entry.PayloadUtf8), headers); err != nil {
+		return nil
+	}
+	return headers
+}
+
+// requestKey identifies a recorded request: request ids are only unique per
+// channel.
+type requestKey struct {
+	namespace string
+	peer      string
+	id        int
+}
+
+func rewriteRequestId(payload string, lookup func(int) (int, bool)) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil {
+		return payload
+	}
+	var recorded int
+	if err := json.Unmarshal(fields["requestId"], &recorded); err != nil {
+		return payload
+	}
+	actual, ok := lookup(recorded)
+	if !ok {
+		return payload
+	}
+	fields["requestId"], _ = json.Marshal(actual)
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return string(rewritten)
+}
+
+func reply(message *api.CastMessage, payload string) *api.CastMessage {
+	return &api.CastMessage{
+		ProtocolVersion: api.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        message.DestinationId,
+		DestinationId:   message.SourceId,
+		Namespace:       message.Namespace,
+		PayloadType:     api.CastMessage_STRING.Enum(),
+		PayloadUtf8:     &payload,
+	}
+}